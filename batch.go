@@ -0,0 +1,400 @@
+package imagemagick
+
+import (
+	"bytes"
+	"context"
+	"sync"
+)
+
+// RetryStrategy decides how GetImageDetailsParallel finds the bad files when a batch fails
+type RetryStrategy int
+
+const (
+	// RetryIndividually re-runs every file in a failed batch on its own, which costs one
+	// `convert` run per file
+	RetryIndividually RetryStrategy = iota
+	// RetryBisect splits a failed batch in halves and retries each half recursively, which
+	// costs about 2*log2(BatchSize) runs per bad file
+	RetryBisect
+)
+
+// String representation
+func (strategy RetryStrategy) String() string {
+	switch strategy {
+	case RetryIndividually:
+		return "individually"
+	case RetryBisect:
+		return "bisect"
+	}
+	return "unknown"
+}
+
+// identifyBatch computes the ImageDetails for a batch of files, passing the results of each file
+// to sendResults and the errors (with the files they apply to) to sendErr.  When the batch fails,
+// the bad files are isolated with the reported file heuristic (if Parser.IsolateReportedFiles is
+// set) and Parser.RetryStrategy.  If the results can't be matched to the files, they are passed to
+// sendUngrouped, or when it's nil, the batch is retried like a failed one.  Every file in the
+// batch is passed to exactly one of the functions.
+func (parser *Parser) identifyBatch(
+	ctx context.Context,
+	batch []string,
+	sendResults func(file string, results []*ImageResult),
+	sendErr func(files []string, err *ParserError),
+	sendUngrouped func(files []string, results []*ImageResult),
+) {
+	// Reject the unsafe files and non-images on their own, before they cost a `convert` run and
+	// without failing the batch
+	if parser.checkInputs() {
+		accepted := make([]string, 0, len(batch))
+		for _, file := range batch {
+			if _, checkErr := parser.checkInput(file); checkErr != nil {
+				sendErr([]string{file}, inputError(file, checkErr))
+				continue
+			}
+			accepted = append(accepted, file)
+		}
+
+		if len(accepted) == 0 {
+			return
+		}
+		batch = accepted
+	}
+
+	results, groups, err := parser.getImageDetails(ctx, batch, nil)
+	if err == nil && groups == nil {
+		if sendUngrouped != nil {
+			sendUngrouped(batch, results)
+			return
+		}
+		err = unmatchedResultsError(batch)
+	}
+	if err == nil {
+		for i, group := range groups {
+			sendResults(batch[i], group)
+		}
+		return
+	}
+
+	// Retrying is pointless once the context is done
+	if len(batch) == 1 || err.Timeout() || err.Canceled() {
+		sendErr(batch, err)
+		return
+	}
+
+	if parser.IsolateReportedFiles {
+		if reported, rest := parser.reportedFiles(batch, err); len(reported) > 0 && len(rest) > 0 {
+			for _, file := range reported {
+				sendErr([]string{file}, fileError(err, file))
+			}
+			parser.identifyBatch(ctx, rest, sendResults, sendErr, sendUngrouped)
+			return
+		}
+	}
+
+	switch parser.RetryStrategy {
+	case RetryBisect:
+		half := len(batch) / 2
+		parser.identifyBatch(ctx, batch[:half], sendResults, sendErr, sendUngrouped)
+		parser.identifyBatch(ctx, batch[half:], sendResults, sendErr, sendUngrouped)
+	default:
+		for _, file := range batch {
+			parser.identifyBatch(ctx, []string{file}, sendResults, sendErr, sendUngrouped)
+		}
+	}
+}
+
+// reportedFiles splits the batch into the files that ImageMagick blamed for the error and the rest
+func (parser *Parser) reportedFiles(batch []string, err *ParserError) (reported []string, rest []string) {
+	var blamed []*Diagnostic
+	for _, diagnostic := range err.Diagnostics() {
+		if diagnostic.File != "" && diagnostic.Severity >= SeverityError {
+			blamed = append(blamed, diagnostic)
+		}
+	}
+	blamed = append(blamed, parser.escalatedWarnings(err.Diagnostics())...)
+
+	for _, file := range batch {
+		isReported := false
+		for _, diagnostic := range blamed {
+			if normalizeImageName(diagnostic.File) == normalizeImageName(file) {
+				isReported = true
+				break
+			}
+		}
+
+		if isReported {
+			reported = append(reported, file)
+		} else {
+			rest = append(rest, file)
+		}
+	}
+
+	return
+}
+
+// fileError creates a copy of a batch error for one of the files in the batch, keeping only the
+// ImageMagick messages about that file
+func fileError(batchErr *ParserError, file string) *ParserError {
+	var stdErr bytes.Buffer
+	for _, diagnostic := range batchErr.Diagnostics() {
+		if normalizeImageName(diagnostic.File) == normalizeImageName(file) {
+			stdErr.WriteString(diagnostic.Raw)
+			stdErr.WriteString("\n")
+		}
+	}
+
+	err := NewParserError(batchErr.msg, file, batchErr.cmd, []byte{}, stdErr.Bytes())
+	err.cause = batchErr.cause
+	return err
+}
+
+// groupResults matches the results of a `convert` run to the input files, so multi-frame files
+// get all their frames, and sets ImageResult.File and ImageResult.Frame.  The results are matched
+// with the names the files were passed to `convert` with, see resultMatcher.  If any of the results
+// can't be matched, groups is nil and ok is false rather than guessing which file they belong to.
+func groupResults(files []string, names []string, results []*ImageResult) (groups [][]*ImageResult, ok bool) {
+	groups = make([][]*ImageResult, len(files))
+
+	matcher := newResultMatcher(files, names)
+	matcher.assign = func(index int, result *ImageResult) {
+		groups[index] = append(groups[index], result)
+	}
+	for _, result := range results {
+		matcher.add(result)
+	}
+	matcher.flush()
+
+	if matcher.unmatched {
+		return nil, false
+	}
+	return groups, true
+}
+
+// fileRun is a file that was given one or more times in a row
+type fileRun struct {
+	name   string
+	index  int
+	copies int
+}
+
+// resultMatcher matches the results of a `convert` run to the files one at a time, setting
+// ImageResult.File and ImageResult.Frame.  ImageMagick keeps the results in the order of the
+// files, so they're matched by Name and BaseName while walking the files, and all the results
+// go to the file when there is only one.  The copies of a file that was given several times in a
+// row can't be told apart by name, so its results are held back until the next file starts and
+// then split evenly between the copies.  Results that can't be matched are left without a File.
+type resultMatcher struct {
+	files []string
+	runs  []fileRun
+
+	// assign is called with the index of the file of each matched result
+	assign func(index int, result *ImageResult)
+
+	current   int
+	frame     int
+	pending   []*ImageResult
+	unmatched bool
+}
+
+// newResultMatcher creates a resultMatcher for the files, which were passed to `convert` with the
+// given names (like "PNG:file.png[0]")
+func newResultMatcher(files []string, names []string) *resultMatcher {
+	matcher := &resultMatcher{files: files}
+	for i, name := range names {
+		if last := len(matcher.runs) - 1; last >= 0 && matcher.runs[last].name == name {
+			matcher.runs[last].copies++
+			continue
+		}
+		matcher.runs = append(matcher.runs, fileRun{name: name, index: i, copies: 1})
+	}
+	return matcher
+}
+
+// add matches the next result and returns the results that are ready, in order
+func (matcher *resultMatcher) add(result *ImageResult) (ready []*ImageResult) {
+	i, found := matcher.matchRun(result)
+	if !found {
+		matcher.unmatched = true
+		return append(matcher.flush(), result)
+	}
+
+	if i != matcher.current {
+		ready = matcher.flush()
+		matcher.current = i
+		matcher.frame = 0
+	}
+
+	run := matcher.runs[i]
+	if run.copies > 1 {
+		matcher.pending = append(matcher.pending, result)
+		return
+	}
+
+	matcher.set(run.index, matcher.frame, result)
+	matcher.frame++
+	return append(ready, result)
+}
+
+// flush returns the held back results of the current file, split evenly between its copies.  If
+// they can't be split evenly they are left without a File.
+func (matcher *resultMatcher) flush() (ready []*ImageResult) {
+	ready, matcher.pending = matcher.pending, nil
+	if len(ready) == 0 {
+		return
+	}
+
+	run := matcher.runs[matcher.current]
+	if len(ready)%run.copies != 0 {
+		matcher.unmatched = true
+		return
+	}
+
+	frames := len(ready) / run.copies
+	for i, result := range ready {
+		matcher.set(run.index+i/frames, i%frames, result)
+	}
+	return
+}
+
+func (matcher *resultMatcher) set(index int, frame int, result *ImageResult) {
+	result.File = matcher.files[index]
+	result.Frame = frame
+	if matcher.assign != nil {
+		matcher.assign(index, result)
+	}
+}
+
+// matchRun returns the index of the first run from the current one on that the result refers to.
+// The full file names are tried first, so files with the same base name in different directories
+// aren't mixed up, and the base names only if none of the remaining files has the same full name.
+func (matcher *resultMatcher) matchRun(result *ImageResult) (int, bool) {
+	if len(matcher.runs) == 1 {
+		return 0, true
+	}
+
+	for _, exact := range []bool{true, false} {
+		for i := matcher.current; i < len(matcher.runs); i++ {
+			if !resultMatchesFile(result, matcher.runs[i].name, exact) {
+				continue
+			}
+
+			// Files given in a row that only differ by their coder or frame selection, like
+			// "anim.gif[0]" and "anim.gif", can't be told apart
+			if i+1 < len(matcher.runs) && normalizeImageName(matcher.runs[i].name) == normalizeImageName(matcher.runs[i+1].name) {
+				return i, false
+			}
+			return i, true
+		}
+	}
+	return matcher.current, false
+}
+
+// queuedFile is an input file with its position in the input
+type queuedFile struct {
+	index int
+	file  string
+}
+
+// identifyParallel computes ImageDetails for a channel of input files in batches across
+// Parser.Workers, passing the results and errors of each file to the given functions along with
+// the position of the file in the input.  See identifyBatch for sendUngrouped, which may be nil.
+// It returns when all the files are done or ctx is done.
+func (parser *Parser) identifyParallel(
+	ctx context.Context,
+	files <-chan string,
+	sendResults func(index int, file string, results []*ImageResult),
+	sendErr func(indexes []int, files []string, err *ParserError),
+	sendUngrouped func(indexes []int, files []string, results []*ImageResult),
+) {
+	// Number the files in the order they are received
+	var receiveLock sync.Mutex
+	received := 0
+	receive := func() (queued queuedFile, ok bool) {
+		receiveLock.Lock()
+		defer receiveLock.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case file, ok := <-files:
+			if !ok {
+				return queued, false
+			}
+			queued = queuedFile{received, file}
+			received++
+			return queued, true
+		}
+	}
+
+	identify := func(batch []queuedFile) {
+		// Remember the positions of the files, which may be repeated
+		positions := map[string][]int{}
+		fileBatch := make([]string, 0, len(batch))
+		for _, queued := range batch {
+			positions[queued.file] = append(positions[queued.file], queued.index)
+			fileBatch = append(fileBatch, queued.file)
+		}
+
+		nextIndex := func(file string) int {
+			index := positions[file][0]
+			positions[file] = positions[file][1:]
+			return index
+		}
+
+		fileIndexes := func(files []string) []int {
+			indexes := make([]int, 0, len(files))
+			for _, file := range files {
+				indexes = append(indexes, nextIndex(file))
+			}
+			return indexes
+		}
+
+		var sendBatchUngrouped func(files []string, results []*ImageResult)
+		if sendUngrouped != nil {
+			sendBatchUngrouped = func(ungroupedFiles []string, results []*ImageResult) {
+				sendUngrouped(fileIndexes(ungroupedFiles), ungroupedFiles, results)
+			}
+		}
+
+		parser.identifyBatch(ctx, fileBatch,
+			func(file string, results []*ImageResult) {
+				sendResults(nextIndex(file), file, results)
+			},
+			func(errFiles []string, err *ParserError) {
+				sendErr(fileIndexes(errFiles), errFiles, err)
+			},
+			sendBatchUngrouped,
+		)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(parser.Workers)
+	for w := 0; w < parser.Workers; w++ {
+
+		go func() {
+			defer wg.Done()
+
+			// Collect a batch of files to pass to ImageMagick
+			batch := []queuedFile{}
+			for {
+				queued, ok := receive()
+				if !ok {
+					break
+				}
+
+				batch = append(batch, queued)
+				if len(batch) == parser.BatchSize {
+					identify(batch)
+					batch = []queuedFile{}
+				}
+			}
+
+			if len(batch) > 0 {
+				identify(batch)
+			}
+		}()
+
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,318 @@
+package imagemagick_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/kamermans/imagemagick"
+	test "github.com/kamermans/imagemagick/test_resources"
+)
+
+// helperArgs returns the arguments that were passed to the mocked command
+func helperArgs() []string {
+	for i, arg := range os.Args {
+		if arg == "--" {
+			// Skip the command name
+			return os.Args[i+2:]
+		}
+	}
+	return []string{}
+}
+
+// TestHelperBatch mocks `convert file1 file2 fileN json:-`, returning one result per file named
+// after the file.  Files with "bad" in the name fail with an error that names the file and files
+// with "multi" in the name have 3 frames.
+func TestHelperBatch(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	args := helperArgs()
+	files := args[:len(args)-1]
+
+	results := []map[string]map[string]interface{}{}
+	for _, file := range files {
+		if strings.Contains(file, "bad") {
+			fmt.Fprintf(os.Stderr, "convert: improper image header `%v' @ error/bmp.c/ReadBMPImage/822.\n", file)
+			os.Exit(1)
+		}
+
+		frames := 1
+		if strings.Contains(file, "multi") {
+			frames = 3
+		}
+
+		for i := 0; i < frames; i++ {
+			results = append(results, map[string]map[string]interface{}{
+				"image": {"name": file, "baseName": file, "format": "PNG"},
+			})
+		}
+	}
+
+	json.NewEncoder(os.Stdout).Encode(results)
+}
+
+func runBatchRetry(t *testing.T, parser *imagemagick.Parser, files []string) (results []*imagemagick.ImageResult, errs []*imagemagick.ParserError) {
+	filesChan := make(chan string)
+	resultsChan := make(chan *imagemagick.ImageResult)
+	errsChan := make(chan *imagemagick.ParserError)
+
+	parser.GetImageDetailsParallel(filesChan, resultsChan, errsChan)
+
+	go func() {
+		defer close(filesChan)
+		for _, file := range files {
+			filesChan <- file
+		}
+	}()
+
+	moreErrs := true
+	moreResults := true
+	for moreErrs || moreResults {
+		select {
+		case err, ok := <-errsChan:
+			if !ok {
+				moreErrs = false
+				continue
+			}
+			errs = append(errs, err)
+		case result, ok := <-resultsChan:
+			if !ok {
+				moreResults = false
+				continue
+			}
+			results = append(results, result)
+		}
+	}
+
+	return
+}
+
+func batchTestFiles(num int, bad ...int) []string {
+	files := []string{}
+	for i := 0; i < num; i++ {
+		files = append(files, fmt.Sprintf("/foo/bar/good_%d.png", i))
+	}
+	for _, i := range bad {
+		files[i] = fmt.Sprintf("/foo/bar/bad_%d.png", i)
+	}
+	return files
+}
+
+func TestGetImageDetailsParallelRetryStrategies(t *testing.T) {
+	tests := []struct {
+		strategy      imagemagick.RetryStrategy
+		isolate       bool
+		expectedRuns  int
+		expectedError string
+	}{
+		// 1 batch + 16 individual runs
+		{imagemagick.RetryIndividually, false, 17, "/foo/bar/bad_5.png"},
+		// 1 batch + 2 halves of 8, 2 quarters of 4, 2 eighths of 2, 2 singles
+		{imagemagick.RetryBisect, false, 9, "/foo/bar/bad_5.png"},
+		// 1 batch + 1 batch without the reported file
+		{imagemagick.RetryIndividually, true, 2, "/foo/bar/bad_5.png"},
+		{imagemagick.RetryBisect, true, 2, "/foo/bar/bad_5.png"},
+	}
+
+	for _, tt := range tests {
+		mockExec := test.NewMockExec("TestHelperBatch")
+
+		parser := imagemagick.NewParser()
+		parser.SetCommand(mockExec.Command)
+		parser.Workers = 1
+		parser.BatchSize = 16
+		parser.RetryStrategy = tt.strategy
+		parser.IsolateReportedFiles = tt.isolate
+
+		results, errs := runBatchRetry(t, parser, batchTestFiles(16, 5))
+
+		if mockExec.RunCount() != tt.expectedRuns {
+			t.Fatalf("Strategy %v (isolate: %v) failed: expected %v runs, got %v", tt.strategy, tt.isolate, tt.expectedRuns, mockExec.RunCount())
+		}
+
+		if len(results) != 15 {
+			t.Fatalf("Strategy %v (isolate: %v) failed: expected 15 results, got %v", tt.strategy, tt.isolate, len(results))
+		}
+
+		if len(errs) != 1 || errs[0].File() != tt.expectedError {
+			t.Fatalf("Strategy %v (isolate: %v) failed: expected 1 error for %v, got %v", tt.strategy, tt.isolate, tt.expectedError, errs)
+		}
+
+		if errs[0].Category() != imagemagick.CategoryCorruptImage {
+			t.Fatalf("Strategy %v (isolate: %v) failed: expected a corrupt image error, got %v", tt.strategy, tt.isolate, errs[0].Category())
+		}
+	}
+}
+
+func TestGetImageDetailsParallelBisectMultipleBadFiles(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperBatch")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+	parser.Workers = 1
+	parser.BatchSize = 8
+	parser.RetryStrategy = imagemagick.RetryBisect
+
+	results, errs := runBatchRetry(t, parser, batchTestFiles(8, 0, 7))
+
+	if len(results) != 6 || len(errs) != 2 {
+		t.Fatalf("RetryBisect failed: expected 6 results and 2 errors, got %v and %v", len(results), len(errs))
+	}
+
+	if imagemagick.RetryBisect.String() != "bisect" {
+		t.Fatalf("RetryStrategy.String() failed: got %v", imagemagick.RetryBisect)
+	}
+}
+
+func TestGetImageDetailsByFile(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperBatch")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+
+	files := []string{"/foo/a.png", "/foo/multi.gif", "/foo/a.png"}
+	fileResults, err := parser.GetImageDetailsByFile(files...)
+	if err != nil {
+		t.Fatalf("GetImageDetailsByFile() failed: %v", err.Error())
+	}
+
+	if len(fileResults) != len(files) {
+		t.Fatalf("GetImageDetailsByFile() failed: expected %v files, got %v", len(files), len(fileResults))
+	}
+
+	expectedFrames := []int{1, 3, 1}
+	for i, fileResult := range fileResults {
+		if fileResult.File != files[i] || len(fileResult.Frames) != expectedFrames[i] {
+			t.Fatalf("GetImageDetailsByFile() failed: expected %v with %v frames, got %v with %v",
+				files[i], expectedFrames[i], fileResult.File, len(fileResult.Frames))
+		}
+
+		for frame, result := range fileResult.Frames {
+			if result.File != files[i] || result.Frame != frame {
+				t.Fatalf("GetImageDetailsByFile() failed: expected frame %v of %v, got frame %v of %v",
+					frame, files[i], result.Frame, result.File)
+			}
+		}
+	}
+}
+
+func TestGetImageDetailsByFileSameBaseName(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperBatch")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+
+	// The frames of b/multi.gif also match a/multi.gif by base name
+	files := []string{"/foo/a/multi.gif", "/foo/b/multi.gif"}
+	fileResults, err := parser.GetImageDetailsByFile(files...)
+	if err != nil {
+		t.Fatalf("GetImageDetailsByFile() failed: %v", err.Error())
+	}
+
+	for i, fileResult := range fileResults {
+		if fileResult.File != files[i] || len(fileResult.Frames) != 3 {
+			t.Fatalf("GetImageDetailsByFile() failed: expected %v with 3 frames, got %v with %v",
+				files[i], fileResult.File, len(fileResult.Frames))
+		}
+
+		for _, result := range fileResult.Frames {
+			if result.Image.Name != files[i] {
+				t.Fatalf("GetImageDetailsByFile() failed: %v has a frame of %v", files[i], result.Image.Name)
+			}
+		}
+	}
+}
+
+func TestGetImageDetailsByFileAdjacentCopies(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperBatch")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+
+	// The results of the copies can't be told apart by name, so they're split between them
+	files := []string{"/foo/a.png", "/foo/a.png", "/foo/multi.gif", "/foo/multi.gif", "/foo/multi.gif"}
+	fileResults, err := parser.GetImageDetailsByFile(files...)
+	if err != nil {
+		t.Fatalf("GetImageDetailsByFile() failed: %v", err.Error())
+	}
+
+	expectedFrames := []int{1, 1, 3, 3, 3}
+	for i, fileResult := range fileResults {
+		if fileResult.File != files[i] || len(fileResult.Frames) != expectedFrames[i] {
+			t.Fatalf("GetImageDetailsByFile() failed: expected %v with %v frames, got %v with %v",
+				files[i], expectedFrames[i], fileResult.File, len(fileResult.Frames))
+		}
+
+		for frame, result := range fileResult.Frames {
+			if result.File != files[i] || result.Frame != frame {
+				t.Fatalf("GetImageDetailsByFile() failed: expected frame %v of %v, got frame %v of %v",
+					frame, files[i], result.Frame, result.File)
+			}
+		}
+	}
+
+	// The same file with and without a frame selection can't be told apart at all
+	if _, err = parser.GetImageDetailsByFile("/foo/multi.gif[0]", "/foo/multi.gif"); err == nil {
+		t.Fatalf("GetImageDetailsByFile() did not fail as expected")
+	}
+}
+
+func TestGetImageDetailsUnmatchedResults(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperGetImageDetailsWarnings")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+
+	// The results are named after other files, so they aren't guessed to belong to these
+	files := []string{"/foo/one.png", "/foo/two.png"}
+	results, err := parser.GetImageDetails(files...)
+	if err != nil {
+		t.Fatalf("GetImageDetails() failed: %v", err.Error())
+	}
+	if len(results) != 4 {
+		t.Fatalf("GetImageDetails() failed: expected 4 results, got %v", len(results))
+	}
+	for _, result := range results {
+		if result.File != "" {
+			t.Fatalf("GetImageDetails() failed: result of %v was matched to %v", result.Image.Name, result.File)
+		}
+	}
+
+	if _, err = parser.GetImageDetailsByFile(files...); err == nil {
+		t.Fatalf("GetImageDetailsByFile() did not fail as expected")
+	}
+}
+
+func TestGetImageDetailsParallelResultFiles(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperBatch")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+	parser.Workers = 2
+	parser.BatchSize = 4
+
+	files := batchTestFiles(10)
+	files[4] = "/foo/bar/multi_4.gif"
+
+	results, errs := runBatchRetry(t, parser, files)
+	if len(errs) != 0 || len(results) != 12 {
+		t.Fatalf("GetImageDetailsParallel() failed: expected 12 results, got %v and %v errors", len(results), len(errs))
+	}
+
+	frames := map[string]int{}
+	for _, result := range results {
+		if result.File != result.Image.Name {
+			t.Fatalf("GetImageDetailsParallel() failed: result for %v has File %v", result.Image.Name, result.File)
+		}
+		frames[result.File]++
+	}
+
+	if frames["/foo/bar/multi_4.gif"] != 3 || len(frames) != len(files) {
+		t.Fatalf("GetImageDetailsParallel() failed: wrong frames per file: %v", frames)
+	}
+}
@@ -0,0 +1,284 @@
+package imagemagick
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ConvertBuilder builds the arguments for the ImageMagick `convert` command, so they are always
+// passed in the right order: read settings (like -density) come before the input files, then the
+// operators are applied in the order they were added, then the output settings (like -quality)
+// and finally the output file.  Each method validates its arguments and the first problem is
+// returned by Args(), for example:
+//
+//	builder := imagemagick.NewConvertBuilder().
+//		Input("photo.jpg").
+//		AutoOrient().
+//		Thumbnail("200x200^").
+//		Gravity("center").
+//		Extent("200x200").
+//		Strip().
+//		Quality(85).
+//		Output("thumb.jpg")
+//	stdOut, stdErr, err := parser.ConvertWith(builder)
+type ConvertBuilder struct {
+	readSettings   []string
+	inputs         []string
+	operators      []string
+	outputSettings []string
+	output         string
+	err            error
+}
+
+// NewConvertBuilder creates a new, empty ConvertBuilder
+func NewConvertBuilder() *ConvertBuilder {
+	return &ConvertBuilder{}
+}
+
+// Gravity values accepted by Gravity(), keyed by their lowercase name
+var builderGravities = map[string]string{}
+
+// Colorspace values accepted by Colorspace(), keyed by their lowercase name
+var builderColorspaces = map[string]string{}
+
+func init() {
+	for _, gravity := range []string{
+		"None", "Center", "East", "Forget", "NorthEast", "North", "NorthWest", "SouthEast", "South", "SouthWest", "West",
+	} {
+		builderGravities[strings.ToLower(gravity)] = gravity
+	}
+
+	for _, colorspace := range []string{
+		"CMY", "CMYK", "Gray", "HCL", "HCLp", "HSB", "HSI", "HSL", "HSV", "HWB", "Lab", "LCHab", "LCHuv", "LinearGray",
+		"LMS", "Log", "Luv", "OHTA", "Rec601YCbCr", "Rec709YCbCr", "RGB", "scRGB", "sRGB", "Transparent", "xyY", "XYZ",
+		"YCbCr", "YCC", "YDbDr", "YIQ", "YPbPr", "YUV",
+	} {
+		builderColorspaces[strings.ToLower(colorspace)] = colorspace
+	}
+}
+
+// fail records the first error encountered while building
+func (b *ConvertBuilder) fail(format string, a ...interface{}) *ConvertBuilder {
+	if b.err == nil {
+		b.err = fmt.Errorf(format, a...)
+	}
+	return b
+}
+
+func (b *ConvertBuilder) operator(args ...string) *ConvertBuilder {
+	b.operators = append(b.operators, args...)
+	return b
+}
+
+func (b *ConvertBuilder) geometryOperator(name string, geometry string) *ConvertBuilder {
+	spec, err := ParseGeometry(geometry)
+	if err != nil {
+		return b.fail("Invalid geometry for %v: %q", name, geometry)
+	}
+	return b.operator(name, spec.String())
+}
+
+// Input adds one or more input files.  A name starting with "-" (like "-photo.jpg") is passed as
+// "./-photo.jpg" so it isn't read as an option, except "-" itself, which reads stdin.
+func (b *ConvertBuilder) Input(files ...string) *ConvertBuilder {
+	for _, file := range files {
+		if file == "" {
+			return b.fail("Input file cannot be empty")
+		}
+		b.inputs = append(b.inputs, escapeFileName(file))
+	}
+	return b
+}
+
+// Output sets the output file, which may include a format prefix like "PNG:out.dat" or "JPEG:-".
+// A name starting with "-" is escaped like the inputs, and "-" writes to stdout.
+func (b *ConvertBuilder) Output(file string) *ConvertBuilder {
+	if file == "" {
+		return b.fail("Output file cannot be empty")
+	}
+	b.output = escapeFileName(file)
+	return b
+}
+
+// escapeFileName keeps a file name like "-resize" from being read as an option
+func escapeFileName(file string) string {
+	if file != "-" && strings.HasPrefix(file, "-") {
+		return "./" + file
+	}
+	return file
+}
+
+// Density sets the resolution used to read vector inputs like PDF and SVG, in DPI
+func (b *ConvertBuilder) Density(dpi float64) *ConvertBuilder {
+	if dpi <= 0 || math.IsNaN(dpi) || math.IsInf(dpi, 0) {
+		return b.fail("Invalid density: %v", dpi)
+	}
+	b.readSettings = append(b.readSettings, "-density", formatFloat(dpi))
+	return b
+}
+
+// Define sets a coder or operator specific option, like Define("jpeg:size", "400x400")
+func (b *ConvertBuilder) Define(key string, value string) *ConvertBuilder {
+	if !strings.Contains(key, ":") || strings.ContainsAny(key, "= ") {
+		return b.fail("Invalid define key: %q", key)
+	}
+	b.readSettings = append(b.readSettings, "-define", key+"="+value)
+	return b
+}
+
+// Resize resizes the image to the given geometry, like "800x600", "50%" or "800x600^"
+func (b *ConvertBuilder) Resize(geometry string) *ConvertBuilder {
+	return b.geometryOperator("-resize", geometry)
+}
+
+// Thumbnail is like Resize, but optimized for small images and strips the profiles
+func (b *ConvertBuilder) Thumbnail(geometry string) *ConvertBuilder {
+	return b.geometryOperator("-thumbnail", geometry)
+}
+
+// Crop cuts out the region of the image given by geometry, like "100x100+10+10".
+// You probably want to call Repage() afterwards.
+func (b *ConvertBuilder) Crop(geometry string) *ConvertBuilder {
+	return b.geometryOperator("-crop", geometry)
+}
+
+// Extent sets the image size, padding with the background color or cropping as needed.
+// The image is positioned according to Gravity().
+func (b *ConvertBuilder) Extent(geometry string) *ConvertBuilder {
+	return b.geometryOperator("-extent", geometry)
+}
+
+// Repage resets the virtual canvas, which is usually needed after cropping
+func (b *ConvertBuilder) Repage() *ConvertBuilder {
+	return b.operator("+repage")
+}
+
+// Gravity sets the placement used by the operators that follow, like Extent() and Crop().
+// Valid values include Center, North, NorthEast, East, SouthEast, South, SouthWest, West,
+// NorthWest and None (case-insensitive).
+func (b *ConvertBuilder) Gravity(gravity string) *ConvertBuilder {
+	value, ok := builderGravities[strings.ToLower(gravity)]
+	if !ok {
+		return b.fail("Invalid gravity: %q", gravity)
+	}
+	return b.operator("-gravity", value)
+}
+
+// Background sets the background color used by the operators that follow, like "white",
+// "#FF000080" or "none"
+func (b *ConvertBuilder) Background(color string) *ConvertBuilder {
+	if color == "" || strings.HasPrefix(color, "-") {
+		return b.fail("Invalid background color: %q", color)
+	}
+	return b.operator("-background", color)
+}
+
+// Rotate rotates the image clockwise by the given number of degrees
+func (b *ConvertBuilder) Rotate(degrees float64) *ConvertBuilder {
+	if math.IsNaN(degrees) || math.IsInf(degrees, 0) {
+		return b.fail("Invalid rotation: %v", degrees)
+	}
+	return b.operator("-rotate", formatFloat(degrees))
+}
+
+// AutoOrient rotates the image according to its EXIF orientation so it displays upright
+func (b *ConvertBuilder) AutoOrient() *ConvertBuilder {
+	return b.operator("-auto-orient")
+}
+
+// Strip removes all profiles and comments from the image
+func (b *ConvertBuilder) Strip() *ConvertBuilder {
+	return b.operator("-strip")
+}
+
+// Colorspace converts the image to the given colorspace, like sRGB, Gray or CMYK (case-insensitive)
+func (b *ConvertBuilder) Colorspace(colorspace string) *ConvertBuilder {
+	value, ok := builderColorspaces[strings.ToLower(colorspace)]
+	if !ok {
+		return b.fail("Invalid colorspace: %q", colorspace)
+	}
+	return b.operator("-colorspace", value)
+}
+
+// Quality sets the compression quality of the output image, from 1 to 100
+func (b *ConvertBuilder) Quality(quality int) *ConvertBuilder {
+	if quality < 1 || quality > 100 {
+		return b.fail("Invalid quality: %v", quality)
+	}
+	b.outputSettings = append(b.outputSettings, "-quality", strconv.Itoa(quality))
+	return b
+}
+
+// Operator adds an option that has no dedicated method, like Operator("-blur", "0x8").  The name
+// must start with "-" or "+", and its arguments must not look like options.
+func (b *ConvertBuilder) Operator(name string, args ...string) *ConvertBuilder {
+	if len(name) < 2 || (name[0] != '-' && name[0] != '+') {
+		return b.fail("Invalid operator: %q", name)
+	}
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") && !isNumber(arg) {
+			return b.fail("Invalid argument for operator %v: %q", name, arg)
+		}
+	}
+	b.operators = append(b.operators, name)
+	return b.operator(args...)
+}
+
+// Err returns the first validation error encountered so far, if any
+func (b *ConvertBuilder) Err() error {
+	return b.err
+}
+
+// Args returns the arguments for the `convert` command, or an error if the builder is invalid
+func (b *ConvertBuilder) Args() (args []string, err error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	if len(b.inputs) == 0 {
+		return nil, fmt.Errorf("No input files were given")
+	}
+
+	if b.output == "" {
+		return nil, fmt.Errorf("No output file was given")
+	}
+
+	args = make([]string, 0, len(b.readSettings)+len(b.inputs)+len(b.operators)+len(b.outputSettings)+1)
+	args = append(args, b.readSettings...)
+	args = append(args, b.inputs...)
+	args = append(args, b.operators...)
+	args = append(args, b.outputSettings...)
+	args = append(args, b.output)
+	return
+}
+
+// ConvertWith runs the `convert` command built by the given ConvertBuilder.  It returns the same
+// values as Convert, and an error without running the command if the builder is invalid.
+func (parser *Parser) ConvertWith(builder *ConvertBuilder) (stdOut *[]byte, stdErr *[]byte, err *ParserError) {
+	return parser.ConvertWithContext(context.Background(), builder)
+}
+
+// ConvertWithContext is like ConvertWith, but the `convert` process is killed if ctx is canceled
+// or its deadline expires before it exits
+func (parser *Parser) ConvertWithContext(ctx context.Context, builder *ConvertBuilder) (stdOut *[]byte, stdErr *[]byte, err *ParserError) {
+	args, buildErr := builder.Args()
+	if buildErr != nil {
+		err = NewParserError(buildErr.Error(), strings.Join(builder.inputs, ", "), "", []byte{}, []byte{})
+		stdOutBytes, stdErrBytes := []byte{}, []byte{}
+		return &stdOutBytes, &stdErrBytes, err
+	}
+
+	return parser.ConvertContext(ctx, args...)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
@@ -0,0 +1,124 @@
+package imagemagick_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/kamermans/imagemagick"
+	test "github.com/kamermans/imagemagick/test_resources"
+)
+
+func TestConvertBuilderArgs(t *testing.T) {
+	builder := imagemagick.NewConvertBuilder().
+		Input("photo.pdf[0]").
+		AutoOrient().
+		Quality(85).
+		Thumbnail("200x200^").
+		Gravity("center").
+		Background("white").
+		Extent("200x200").
+		Colorspace("srgb").
+		Rotate(-90).
+		Strip().
+		Density(150).
+		Output("JPEG:thumb.jpg")
+
+	args, err := builder.Args()
+	if err != nil {
+		t.Fatalf("Args() failed: %v", err.Error())
+	}
+
+	expected := []string{
+		"-density", "150",
+		"photo.pdf[0]",
+		"-auto-orient",
+		"-thumbnail", "200x200^",
+		"-gravity", "Center",
+		"-background", "white",
+		"-extent", "200x200",
+		"-colorspace", "sRGB",
+		"-rotate", "-90",
+		"-strip",
+		"-quality", "85",
+		"JPEG:thumb.jpg",
+	}
+
+	if strings.Join(args, " ") != strings.Join(expected, " ") {
+		t.Fatalf("Args() failed: expected %v, got %v", expected, args)
+	}
+}
+
+func TestConvertBuilderValidation(t *testing.T) {
+	tests := map[string]*imagemagick.ConvertBuilder{
+		"no input":       imagemagick.NewConvertBuilder().Output("out.png"),
+		"no output":      imagemagick.NewConvertBuilder().Input("in.png"),
+		"bad geometry":   imagemagick.NewConvertBuilder().Input("in.png").Resize("-resize").Output("out.png"),
+		"empty geometry": imagemagick.NewConvertBuilder().Input("in.png").Crop("").Output("out.png"),
+		"no dimensions":  imagemagick.NewConvertBuilder().Input("in.png").Extent("x").Output("out.png"),
+		"only flags":     imagemagick.NewConvertBuilder().Input("in.png").Thumbnail("%!").Output("out.png"),
+		"spaces":         imagemagick.NewConvertBuilder().Input("in.png").Resize("8 0 0").Output("out.png"),
+		"bad gravity":    imagemagick.NewConvertBuilder().Input("in.png").Gravity("middle").Output("out.png"),
+		"bad colorspace": imagemagick.NewConvertBuilder().Input("in.png").Colorspace("rainbow").Output("out.png"),
+		"bad quality":    imagemagick.NewConvertBuilder().Input("in.png").Quality(101).Output("out.png"),
+		"bad density":    imagemagick.NewConvertBuilder().Density(0).Input("in.pdf").Output("out.png"),
+		"NaN density":    imagemagick.NewConvertBuilder().Density(math.NaN()).Input("in.pdf").Output("out.png"),
+		"Inf density":    imagemagick.NewConvertBuilder().Density(math.Inf(1)).Input("in.pdf").Output("out.png"),
+		"NaN rotation":   imagemagick.NewConvertBuilder().Input("in.png").Rotate(math.NaN()).Output("out.png"),
+		"Inf rotation":   imagemagick.NewConvertBuilder().Input("in.png").Rotate(math.Inf(-1)).Output("out.png"),
+		"bad define":     imagemagick.NewConvertBuilder().Define("size", "10").Input("in.png").Output("out.png"),
+		"bad operator":   imagemagick.NewConvertBuilder().Input("in.png").Operator("blur", "0x8").Output("out.png"),
+		"bad op arg":     imagemagick.NewConvertBuilder().Input("in.png").Operator("-blur", "-write").Output("out.png"),
+	}
+
+	for name, builder := range tests {
+		if _, err := builder.Args(); err == nil {
+			t.Fatalf("Args() did not fail as expected for %v", name)
+		}
+	}
+
+	args, err := imagemagick.NewConvertBuilder().Input("-resize", "-").Output("-write").Args()
+	if err != nil || strings.Join(args, " ") != "./-resize - ./-write" {
+		t.Fatalf("Args() failed: file names should not be read as options: %v, %v", args, err)
+	}
+
+	valid := []string{"800x600", "800x600^", "50%", "100x100+10-5!", "@10000", "x600", "800x", "800x600>", "25%x50%"}
+	for _, geometry := range valid {
+		if _, err := imagemagick.NewConvertBuilder().Input("in.png").Resize(geometry).Output("out.png").Args(); err != nil {
+			t.Fatalf("Args() failed for geometry %q: %v", geometry, err.Error())
+		}
+	}
+}
+
+func TestConvertWith(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperGetImageDetails")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+
+	builder := imagemagick.NewConvertBuilder().
+		Input("in.png").
+		Resize("50%").
+		Operator("-blur", "0x8").
+		Output("out.png")
+
+	_, _, err := parser.ConvertWith(builder)
+	if err != nil {
+		t.Fatalf("ConvertWith() failed: %v", err.Error())
+	}
+
+	expected := "in.png -resize 50% -blur 0x8 out.png"
+	actual := strings.Join(mockExec.LastRun().Args(), " ")
+	if actual != expected {
+		t.Fatalf("ConvertWith() failed: expected %v, got %v", expected, actual)
+	}
+
+	_, stdErr, err := parser.ConvertWith(imagemagick.NewConvertBuilder().Input("in.png"))
+	if err == nil || stdErr == nil {
+		t.Fatalf("ConvertWith() did not fail as expected")
+	}
+
+	if mockExec.RunCount() != 1 {
+		t.Fatalf("ConvertWith() failed: an invalid builder should not be run")
+	}
+}
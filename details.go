@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
-	"strconv"
 	"strings"
 )
 
@@ -12,6 +11,17 @@ import (
 // property, but this wrapper is left here for future use, should other types by introduced
 type ImageResult struct {
 	Image *ImageDetails `json:"image"`
+
+	// Warnings reported by ImageMagick while reading this image, like "Corrupt JPEG data".  Warnings
+	// that can't be attributed to a single file are added to all the results from the same command.
+	Warnings []*Diagnostic `json:"-"`
+
+	// File is the input file this result was read from, exactly as it was given to GetImageDetails.
+	// ImageMagick rewrites Image.Name and Image.BaseName, so use File to find the input.
+	File string `json:"-"`
+	// Frame is the position of this result among the results of File, starting at 0.  Multi-frame
+	// files like GIFs and PDFs have one result per frame (see Image.Scene for the scene number).
+	Frame int `json:"-"`
 }
 
 // ImageDetails provides detailed information on the image, there are many helpful methods on this object
@@ -68,10 +78,11 @@ type ImageDetails struct {
 	Version           string                            `json:"version"`             //"/usr/local/share/doc/ImageMagick-7//index.html"
 }
 
-// Size of the image in bytes. ImageMagick returns a strangely-formatted string and this the in64 equivalent
+// Size of the image in bytes. ImageMagick returns a strangely-formatted string and this the in64 equivalent.
+// It returns 0 if the Filesize can't be parsed, use FilesizeBytes to get the error.
 func (details ImageDetails) Size() int64 {
-	size, _ := strconv.Atoi(strings.Trim(details.Filesize, "B"))
-	return int64(size)
+	size, _ := details.FilesizeBytes()
+	return size
 }
 
 // ProfileSizePercent returns the percentage of the total filesize which is used by the profiles
@@ -0,0 +1,233 @@
+package imagemagick
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Severity of an ImageMagick diagnostic message
+type Severity int
+
+// ImageMagick diagnostic severities
+const (
+	SeverityUnknown Severity = iota
+	SeverityWarning
+	SeverityError
+	SeverityFatal
+)
+
+// String representation
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	case SeverityFatal:
+		return "fatal"
+	}
+	return "unknown"
+}
+
+// ErrorCategory classifies an ImageMagick diagnostic message by its cause
+type ErrorCategory int
+
+// ImageMagick diagnostic categories
+const (
+	CategoryUnknown ErrorCategory = iota
+	CategoryNoDecodeDelegate
+	CategoryNoEncodeDelegate
+	CategoryCorruptImage
+	CategoryPolicyDenied
+	CategoryInsufficientImageData
+	CategoryUnableToOpen
+	CategoryResourceLimit
+)
+
+// String representation
+func (c ErrorCategory) String() string {
+	switch c {
+	case CategoryNoDecodeDelegate:
+		return "no decode delegate"
+	case CategoryNoEncodeDelegate:
+		return "no encode delegate"
+	case CategoryCorruptImage:
+		return "corrupt image"
+	case CategoryPolicyDenied:
+		return "policy denied"
+	case CategoryInsufficientImageData:
+		return "insufficient image data"
+	case CategoryUnableToOpen:
+		return "unable to open"
+	case CategoryResourceLimit:
+		return "resource limit"
+	}
+	return "unknown"
+}
+
+// Sentinel errors for the ImageMagick diagnostic categories.  A ParserError matches them with
+// errors.Is() when convert reported a diagnostic of the corresponding category, for example:
+//
+//	if errors.Is(err, imagemagick.ErrNoDecodeDelegate) {
+//		// Not an image, or a format that this ImageMagick can't read
+//	}
+var (
+	ErrNoDecodeDelegate      = errors.New("imagemagick: no decode delegate for this image format")
+	ErrNoEncodeDelegate      = errors.New("imagemagick: no encode delegate for this image format")
+	ErrCorruptImage          = errors.New("imagemagick: corrupt image")
+	ErrPolicyDenied          = errors.New("imagemagick: not authorized by the security policy")
+	ErrInsufficientImageData = errors.New("imagemagick: insufficient image data")
+	ErrUnableToOpen          = errors.New("imagemagick: unable to open file")
+	ErrResourceLimit         = errors.New("imagemagick: resource limit exceeded")
+)
+
+// Err returns the sentinel error for this category, or nil for CategoryUnknown
+func (c ErrorCategory) Err() error {
+	switch c {
+	case CategoryNoDecodeDelegate:
+		return ErrNoDecodeDelegate
+	case CategoryNoEncodeDelegate:
+		return ErrNoEncodeDelegate
+	case CategoryCorruptImage:
+		return ErrCorruptImage
+	case CategoryPolicyDenied:
+		return ErrPolicyDenied
+	case CategoryInsufficientImageData:
+		return ErrInsufficientImageData
+	case CategoryUnableToOpen:
+		return ErrUnableToOpen
+	case CategoryResourceLimit:
+		return ErrResourceLimit
+	}
+	return nil
+}
+
+// The reason fragments used to categorize diagnostics, checked in order
+var diagnosticCategories = []struct {
+	category  ErrorCategory
+	fragments []string
+}{
+	{CategoryPolicyDenied, []string{"not authorized", "security policy"}},
+	{CategoryNoDecodeDelegate, []string{"no decode delegate", "delegate library support not built-in"}},
+	{CategoryNoEncodeDelegate, []string{"no encode delegate"}},
+	{CategoryUnableToOpen, []string{"unable to open", "no such file", "unable to read file"}},
+	{CategoryResourceLimit, []string{
+		"resources exhausted", "memory allocation failed", "exceeds limit", "resource limit",
+		"time limit exceeded", "too many",
+	}},
+	{CategoryInsufficientImageData, []string{"insufficient image data", "unexpected end-of-file", "unexpected end of file"}},
+	{CategoryCorruptImage, []string{
+		"corrupt", "improper image header", "not a jpeg file", "invalid sos parameters", "premature end",
+		"negative or zero image size", "bogus", "invalid colormap index", "crc error", "length and filesize do not match",
+		"unable to read image data",
+	}},
+}
+
+// Diagnostic is a single error or warning message written to stderr by ImageMagick, like:
+//
+//	convert: no decode delegate for this image format `FOO' @ error/constitute.c/ReadImage/575.
+type Diagnostic struct {
+	// Severity comes from the module path, like "error/constitute.c"
+	Severity Severity
+	// Category is derived from Reason
+	Category ErrorCategory
+	// Tool is the command that reported the message, like "convert" or "convert-im6.q16"
+	Tool string
+	// Reason is the message up to the quoted file, like "no decode delegate for this image format"
+	Reason string
+	// File is the quoted value in the message, which is usually the file that caused it (some
+	// messages quote the image format instead)
+	File string
+	// Detail is the message after the quoted file, like "No such file or directory"
+	Detail string
+	// Module, Function and Line locate the message in the ImageMagick source, like
+	// "constitute.c", "ReadImage" and 575
+	Module   string
+	Function string
+	Line     int
+	// Raw is the original line
+	Raw string
+}
+
+var (
+	diagnosticPattern = regexp.MustCompile(`^(?:(\S+): )?(.*?)\s*@ (warning|error|fatal)/([^/\s]+)/([^/\s]+)/(\d+)\.?\s*$`)
+	quotedPattern     = regexp.MustCompile("[`'‘]([^`'‘’]*)['’]")
+)
+
+// ParseDiagnostics parses the ImageMagick messages in stderr output.  Lines that are not in the
+// ImageMagick message format (like output from delegates) are ignored.
+func ParseDiagnostics(stdErr []byte) (diagnostics []*Diagnostic) {
+	scanner := bufio.NewScanner(bytes.NewReader(stdErr))
+	for scanner.Scan() {
+		if diagnostic := ParseDiagnostic(scanner.Text()); diagnostic != nil {
+			diagnostics = append(diagnostics, diagnostic)
+		}
+	}
+	return
+}
+
+// ParseDiagnostic parses a single ImageMagick message, returning nil if the line is not in the
+// ImageMagick message format
+func ParseDiagnostic(line string) *Diagnostic {
+	line = strings.TrimSpace(line)
+	match := diagnosticPattern.FindStringSubmatch(line)
+	if match == nil {
+		return nil
+	}
+
+	diagnostic := &Diagnostic{
+		Tool:     match[1],
+		Reason:   match[2],
+		Module:   match[4],
+		Function: match[5],
+		Raw:      line,
+	}
+	diagnostic.Line, _ = strconv.Atoi(match[6])
+
+	switch match[3] {
+	case "warning":
+		diagnostic.Severity = SeverityWarning
+	case "error":
+		diagnostic.Severity = SeverityError
+	case "fatal":
+		diagnostic.Severity = SeverityFatal
+	}
+
+	// The last quoted value is the file, anything after it is detail
+	if quotes := quotedPattern.FindAllStringSubmatchIndex(match[2], -1); len(quotes) > 0 {
+		last := quotes[len(quotes)-1]
+		diagnostic.File = match[2][last[2]:last[3]]
+		diagnostic.Reason = strings.TrimSpace(match[2][:last[0]])
+		diagnostic.Detail = strings.TrimSpace(strings.TrimPrefix(match[2][last[1]:], ":"))
+	}
+
+	diagnostic.Category = categorize(diagnostic.Reason + " " + diagnostic.Detail)
+
+	return diagnostic
+}
+
+func categorize(message string) ErrorCategory {
+	message = strings.ToLower(message)
+	for _, c := range diagnosticCategories {
+		for _, fragment := range c.fragments {
+			if strings.Contains(message, fragment) {
+				return c.category
+			}
+		}
+	}
+	return CategoryUnknown
+}
+
+// Error returns the original message, so a Diagnostic can be used as an error
+func (d *Diagnostic) Error() string {
+	return d.Raw
+}
+
+// Is allows errors.Is() to match a Diagnostic against the category sentinel errors
+func (d *Diagnostic) Is(target error) bool {
+	return target != nil && d.Category.Err() == target
+}
@@ -0,0 +1,89 @@
+package imagemagick_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kamermans/imagemagick"
+)
+
+const sampleStdErr = "convert: no decode delegate for this image format `FOO' @ error/constitute.c/ReadImage/575.\n" +
+	"convert-im6.q16: unable to open image `/tmp/missing.jpg': No such file or directory @ error/blob.c/OpenBlob/2874.\n" +
+	"convert: Corrupt JPEG data: premature end of data segment `/tmp/broken.jpg' @ warning/jpeg.c/JPEGWarningHandler/389.\n" +
+	"convert: attempt to perform an operation not allowed by the security policy `PDF' @ error/constitute.c/IsCoderAuthorized/421.\n" +
+	"Error: /undefined in --get--\n" +
+	"magick: insufficient image data in file '/tmp/short.png' @ error/png.c/ReadPNGImage/4213.\n" +
+	"convert: cache resources exhausted `/tmp/huge.tif' @ fatal/cache.c/OpenPixelCache/4095.\n" +
+	"convert: no images defined `json:-' @ error/convert.c/ConvertImageCommand/3258.\n"
+
+func TestParseDiagnostics(t *testing.T) {
+	diagnostics := imagemagick.ParseDiagnostics([]byte(sampleStdErr))
+	if len(diagnostics) != 7 {
+		t.Fatalf("ParseDiagnostics() failed: expected 7 diagnostics, got %v", len(diagnostics))
+	}
+
+	expected := []struct {
+		tool     string
+		severity imagemagick.Severity
+		category imagemagick.ErrorCategory
+		file     string
+		module   string
+	}{
+		{"convert", imagemagick.SeverityError, imagemagick.CategoryNoDecodeDelegate, "FOO", "constitute.c"},
+		{"convert-im6.q16", imagemagick.SeverityError, imagemagick.CategoryUnableToOpen, "/tmp/missing.jpg", "blob.c"},
+		{"convert", imagemagick.SeverityWarning, imagemagick.CategoryCorruptImage, "/tmp/broken.jpg", "jpeg.c"},
+		{"convert", imagemagick.SeverityError, imagemagick.CategoryPolicyDenied, "PDF", "constitute.c"},
+		{"magick", imagemagick.SeverityError, imagemagick.CategoryInsufficientImageData, "/tmp/short.png", "png.c"},
+		{"convert", imagemagick.SeverityFatal, imagemagick.CategoryResourceLimit, "/tmp/huge.tif", "cache.c"},
+		{"convert", imagemagick.SeverityError, imagemagick.CategoryUnknown, "json:-", "convert.c"},
+	}
+
+	for i, e := range expected {
+		d := diagnostics[i]
+		if d.Tool != e.tool || d.Severity != e.severity || d.Category != e.category || d.File != e.file || d.Module != e.module {
+			t.Fatalf("ParseDiagnostics() failed on line %v: expected %+v, got %+v", i, e, d)
+		}
+	}
+
+	missing := diagnostics[1]
+	if missing.Reason != "unable to open image" || missing.Detail != "No such file or directory" {
+		t.Fatalf("ParseDiagnostics() failed: wrong reason / detail: %q / %q", missing.Reason, missing.Detail)
+	}
+
+	if missing.Function != "OpenBlob" || missing.Line != 2874 {
+		t.Fatalf("ParseDiagnostics() failed: wrong function / line: %v / %v", missing.Function, missing.Line)
+	}
+
+	if missing.Severity.String() != "error" || missing.Category.String() != "unable to open" {
+		t.Fatalf("String() failed: %v, %v", missing.Severity, missing.Category)
+	}
+
+	if imagemagick.ParseDiagnostic("just some noise") != nil {
+		t.Fatalf("ParseDiagnostic() should return nil for other output")
+	}
+}
+
+func TestDiagnosticIs(t *testing.T) {
+	d := imagemagick.ParseDiagnostic("convert: improper image header `/tmp/x.bmp' @ error/bmp.c/ReadBMPImage/822.")
+	if d == nil {
+		t.Fatalf("ParseDiagnostic() failed")
+	}
+
+	if !errors.Is(d, imagemagick.ErrCorruptImage) || errors.Is(d, imagemagick.ErrUnableToOpen) {
+		t.Fatalf("Diagnostic.Is() failed for category %v", d.Category)
+	}
+
+	// Failing to read the data of a file that was opened is not an open failure
+	for _, message := range []string{
+		"convert: unable to read image data `/tmp/x.pcx' @ error/pcx.c/ReadPCXImage/521.",
+		"convert: unable to read image `/tmp/x.tga': corrupt image @ error/tga.c/ReadTGAImage/289.",
+	} {
+		if d := imagemagick.ParseDiagnostic(message); d == nil || d.Category != imagemagick.CategoryCorruptImage {
+			t.Fatalf("ParseDiagnostic(%q) failed: expected %v, got %+v", message, imagemagick.CategoryCorruptImage, d)
+		}
+	}
+
+	if imagemagick.CategoryUnknown.Err() != nil {
+		t.Fatalf("CategoryUnknown.Err() should be nil")
+	}
+}
@@ -1,6 +1,9 @@
 package imagemagick
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+)
 
 // ParserError represents an error by the parser
 type ParserError struct {
@@ -9,6 +12,9 @@ type ParserError struct {
 	cmd    string
 	stdOut []byte
 	stdErr []byte
+	cause  error
+
+	diagnostics []*Diagnostic
 }
 
 // NewParserError creates a new ParserError
@@ -19,6 +25,8 @@ func NewParserError(msg string, file string, cmd string, stdOut []byte, stdErr [
 		cmd:    cmd,
 		stdOut: stdOut,
 		stdErr: stdErr,
+
+		diagnostics: ParseDiagnostics(stdErr),
 	}
 }
 
@@ -61,3 +69,87 @@ func (err *ParserError) StdOut() []byte {
 func (err *ParserError) StdErr() []byte {
 	return err.stdErr
 }
+
+// Timeout returns true if the command was killed because its context deadline expired
+func (err *ParserError) Timeout() bool {
+	return err.cause == context.DeadlineExceeded
+}
+
+// Canceled returns true if the command was killed because its context was canceled
+func (err *ParserError) Canceled() bool {
+	return err.cause == context.Canceled
+}
+
+// Unwrap returns the underlying cause of the error (if any), so a timeout can be detected with
+// errors.Is(err, context.DeadlineExceeded)
+func (err *ParserError) Unwrap() error {
+	return err.cause
+}
+
+// Diagnostics returns the ImageMagick errors and warnings parsed from StdErr (if any)
+func (err *ParserError) Diagnostics() []*Diagnostic {
+	return err.diagnostics
+}
+
+// Category returns the category of the first ImageMagick error in StdErr, falling back to the
+// first warning, or CategoryUnknown if there are none
+func (err *ParserError) Category() ErrorCategory {
+	for _, diagnostic := range err.diagnostics {
+		if diagnostic.Severity >= SeverityError && diagnostic.Category != CategoryUnknown {
+			return diagnostic.Category
+		}
+	}
+
+	for _, diagnostic := range err.diagnostics {
+		if diagnostic.Category != CategoryUnknown {
+			return diagnostic.Category
+		}
+	}
+
+	return CategoryUnknown
+}
+
+// Is allows errors.Is() to match the error against the category sentinel errors, like
+// ErrNoDecodeDelegate, if any of the ImageMagick errors in StdErr have that category.  The
+// warnings are only matched when there are no errors, like when a warning is escalated with
+// Parser.WarningsAsErrors.
+func (err *ParserError) Is(target error) bool {
+	hasErrors := false
+	for _, diagnostic := range err.diagnostics {
+		if diagnostic.Severity >= SeverityError {
+			hasErrors = true
+			if diagnostic.Is(target) {
+				return true
+			}
+		}
+	}
+	if hasErrors {
+		return false
+	}
+
+	for _, diagnostic := range err.diagnostics {
+		if diagnostic.Is(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As allows errors.As() to extract the first ImageMagick error (or warning, if there are no
+// errors) from StdErr into a *Diagnostic
+func (err *ParserError) As(target interface{}) bool {
+	diagnosticTarget, ok := target.(**Diagnostic)
+	if !ok || len(err.diagnostics) == 0 {
+		return false
+	}
+
+	*diagnosticTarget = err.diagnostics[0]
+	for _, diagnostic := range err.diagnostics {
+		if diagnostic.Severity >= SeverityError {
+			*diagnosticTarget = diagnostic
+			break
+		}
+	}
+
+	return true
+}
@@ -1,6 +1,8 @@
 package imagemagick_test
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
@@ -83,3 +85,65 @@ func TestParserErrorGetters(t *testing.T) {
 	}
 
 }
+
+func TestParserErrorNotTimeout(t *testing.T) {
+
+	err := getTestError()
+
+	if err.Timeout() {
+		t.Fatalf("Timeout() should be false for an error without a cause")
+	}
+
+	if err.Canceled() {
+		t.Fatalf("Canceled() should be false for an error without a cause")
+	}
+
+	if err.Unwrap() != nil {
+		t.Fatalf("Unwrap() should be nil for an error without a cause")
+	}
+}
+
+func TestParserErrorDiagnostics(t *testing.T) {
+
+	stdErr := []byte("convert: Corrupt JPEG data: premature end of data segment `a.jpg' @ warning/jpeg.c/JPEGWarningHandler/389.\n" +
+		"convert: unable to open image `b.jpg': No such file or directory @ error/blob.c/OpenBlob/2874.\n")
+	err := imagemagick.NewParserError("failed", "a.jpg, b.jpg", "convert a.jpg b.jpg json:-", []byte{}, stdErr)
+
+	if len(err.Diagnostics()) != 2 {
+		t.Fatalf("Diagnostics() failed: expected 2, got %v", len(err.Diagnostics()))
+	}
+
+	if err.Category() != imagemagick.CategoryUnableToOpen {
+		t.Fatalf("Category() failed: expected %v, got %v", imagemagick.CategoryUnableToOpen, err.Category())
+	}
+
+	var asError error = err
+	if !errors.Is(asError, imagemagick.ErrUnableToOpen) {
+		t.Fatalf("errors.Is() failed to match the diagnostics")
+	}
+
+	// The warning didn't cause the failure
+	if errors.Is(asError, imagemagick.ErrCorruptImage) || errors.Is(asError, imagemagick.ErrPolicyDenied) {
+		t.Fatalf("errors.Is() matched an unexpected category")
+	}
+
+	// Without errors, the warnings are matched
+	var warningErr error = imagemagick.NewParserError("failed", "a.jpg", "convert a.jpg json:-", []byte{}, stdErr[:bytes.IndexByte(stdErr, '\n')+1])
+	if !errors.Is(warningErr, imagemagick.ErrCorruptImage) {
+		t.Fatalf("errors.Is() failed to match the warning")
+	}
+
+	var diagnostic *imagemagick.Diagnostic
+	if !errors.As(asError, &diagnostic) || diagnostic.File != "b.jpg" {
+		t.Fatalf("errors.As() failed to extract the error diagnostic: %v", diagnostic)
+	}
+
+	var parserErr *imagemagick.ParserError
+	if !errors.As(asError, &parserErr) || parserErr != err {
+		t.Fatalf("errors.As() failed to extract the ParserError")
+	}
+
+	if getTestError().Category() != imagemagick.CategoryUnknown {
+		t.Fatalf("Category() should be unknown without diagnostics")
+	}
+}
@@ -0,0 +1,340 @@
+package imagemagick
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrTagNotFound is returned by the Exif accessors when the image doesn't have the tag
+var ErrTagNotFound = errors.New("imagemagick: tag not found")
+
+// Rational is an EXIF rational number, which ImageMagick prints like "1/250"
+type Rational struct {
+	Num int64
+	Den int64
+}
+
+// ParseRational parses a rational number like "1/250", or a whole number like "400"
+func ParseRational(s string) (r Rational, err error) {
+	s = strings.TrimSpace(s)
+	parts := strings.SplitN(s, "/", 2)
+
+	if r.Num, err = strconv.ParseInt(strings.TrimSpace(parts[0]), 10, 64); err != nil {
+		return Rational{}, fmt.Errorf("Invalid rational: %q", s)
+	}
+
+	r.Den = 1
+	if len(parts) == 2 {
+		if r.Den, err = strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64); err != nil {
+			return Rational{}, fmt.Errorf("Invalid rational: %q", s)
+		}
+	}
+
+	return r, nil
+}
+
+// ParseRationals parses a list of rational numbers like "40/1, 26/1, 4633/100"
+func ParseRationals(s string) (rs []Rational, err error) {
+	for _, part := range strings.Split(s, ",") {
+		r, err := ParseRational(part)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, r)
+	}
+	return
+}
+
+// Float64 returns the value of the rational number, which is NaN or infinite if Den is 0
+func (r Rational) Float64() float64 {
+	return float64(r.Num) / float64(r.Den)
+}
+
+// String representation
+func (r Rational) String() string {
+	return fmt.Sprintf("%d/%d", r.Num, r.Den)
+}
+
+// Flash is the EXIF Flash tag, a bit field describing whether and how the flash fired
+type Flash uint16
+
+// Fired returns true if the flash fired
+func (f Flash) Fired() bool {
+	return f&0x01 != 0
+}
+
+// RedEyeReduction returns true if red-eye reduction was used
+func (f Flash) RedEyeReduction() bool {
+	return f&0x40 != 0
+}
+
+// Present returns true if the camera has a flash
+func (f Flash) Present() bool {
+	return f&0x20 == 0
+}
+
+// Mode returns the flash mode: "compulsory on", "compulsory off", "auto" or "unknown"
+func (f Flash) Mode() string {
+	switch f >> 3 & 0x03 {
+	case 1:
+		return "compulsory on"
+	case 2:
+		return "compulsory off"
+	case 3:
+		return "auto"
+	}
+	return "unknown"
+}
+
+// String representation
+func (f Flash) String() string {
+	if !f.Present() {
+		return "no flash"
+	}
+
+	fired := "did not fire"
+	if f.Fired() {
+		fired = "fired"
+	}
+	return fmt.Sprintf("%v (%v)", fired, f.Mode())
+}
+
+// WhiteBalance is the EXIF WhiteBalance tag
+type WhiteBalance int
+
+// EXIF white balance modes
+const (
+	WhiteBalanceAuto WhiteBalance = iota
+	WhiteBalanceManual
+)
+
+// String representation
+func (wb WhiteBalance) String() string {
+	switch wb {
+	case WhiteBalanceAuto:
+		return "auto"
+	case WhiteBalanceManual:
+		return "manual"
+	}
+	return "unknown"
+}
+
+// Exif provides typed access to the EXIF tags of an image, which ImageMagick reports as
+// "exif:*" properties in ImageDetails.Properties
+type Exif struct {
+	tags map[string]string
+}
+
+// Exif returns the EXIF tags of the image with typed accessors.  An image without EXIF tags
+// returns ErrTagNotFound from all the accessors.
+func (details ImageDetails) Exif() *Exif {
+	return &Exif{tags: details.ExifTags()}
+}
+
+// Tag returns the raw value of a tag, like Tag("Software")
+func (exif *Exif) Tag(name string) (value string, err error) {
+	value, ok := exif.tags[name]
+	if !ok {
+		return "", fmt.Errorf("%w: exif:%v", ErrTagNotFound, name)
+	}
+	return strings.TrimSpace(value), nil
+}
+
+// firstTag returns the name and raw value of the first of the given tags that is present
+func (exif *Exif) firstTag(names ...string) (name string, value string, err error) {
+	for _, name = range names {
+		if value, err = exif.Tag(name); err == nil {
+			return
+		}
+	}
+	return
+}
+
+// intTag returns the value of a tag as an integer, using the first value of a list like "8, 8, 8"
+func (exif *Exif) intTag(names ...string) (int64, error) {
+	name, value, err := exif.firstTag(names...)
+	if err != nil {
+		return 0, err
+	}
+
+	value = strings.TrimSpace(strings.SplitN(value, ",", 2)[0])
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid integer in exif:%v: %q", name, value)
+	}
+	return i, nil
+}
+
+// rationalTag returns the value of a tag as a Rational
+func (exif *Exif) rationalTag(name string) (Rational, error) {
+	value, err := exif.Tag(name)
+	if err != nil {
+		return Rational{}, err
+	}
+	return ParseRational(value)
+}
+
+// floatTag returns the value of a rational tag as a float64, rejecting a 0 denominator
+func (exif *Exif) floatTag(name string) (float64, error) {
+	r, err := exif.rationalTag(name)
+	if err != nil {
+		return 0, err
+	}
+	if r.Den == 0 {
+		return 0, fmt.Errorf("Invalid rational in exif:%v: %v", name, r)
+	}
+	return r.Float64(), nil
+}
+
+// The EXIF date format, like "2017:07:05 15:02:35"
+const exifDateLayout = "2006:01:02 15:04:05"
+
+// timeTag parses a date tag with its sub-second and offset tags.  Without an offset tag, the
+// time zone is unknown and the time is returned in UTC.
+func (exif *Exif) timeTag(name string, subSecName string, offsetName string) (t time.Time, err error) {
+	value, err := exif.Tag(name)
+	if err != nil {
+		return
+	}
+
+	location := time.UTC
+	if offset, offsetErr := exif.Tag(offsetName); offsetErr == nil {
+		offsetTime, parseErr := time.Parse("-07:00", offset)
+		if parseErr != nil {
+			return t, fmt.Errorf("Invalid offset in exif:%v: %q", offsetName, offset)
+		}
+		_, seconds := offsetTime.Zone()
+		location = time.FixedZone(offset, seconds)
+	}
+
+	if t, err = time.ParseInLocation(exifDateLayout, value, location); err != nil {
+		return t, fmt.Errorf("Invalid date in exif:%v: %q", name, value)
+	}
+
+	// Sub-second digits, like "123" for 0.123 seconds
+	if subSec, subSecErr := exif.Tag(subSecName); subSecErr == nil && subSec != "" {
+		if fraction, parseErr := strconv.ParseFloat("0."+subSec, 64); parseErr == nil {
+			t = t.Add(time.Duration(fraction * float64(time.Second)))
+		}
+	}
+
+	return t, nil
+}
+
+// DateTimeOriginal returns the time the photo was taken, with the time zone from
+// OffsetTimeOriginal (or UTC if the offset is unknown) and the fraction from SubSecTimeOriginal
+func (exif *Exif) DateTimeOriginal() (time.Time, error) {
+	return exif.timeTag("DateTimeOriginal", "SubSecTimeOriginal", "OffsetTimeOriginal")
+}
+
+// DateTimeDigitized returns the time the image was digitized, like DateTimeOriginal
+func (exif *Exif) DateTimeDigitized() (time.Time, error) {
+	return exif.timeTag("DateTimeDigitized", "SubSecTimeDigitized", "OffsetTimeDigitized")
+}
+
+// DateTime returns the time the file was last changed, like DateTimeOriginal
+func (exif *Exif) DateTime() (time.Time, error) {
+	return exif.timeTag("DateTime", "SubSecTime", "OffsetTime")
+}
+
+// ExposureTime returns the exposure time in seconds as a Rational, like 1/250
+func (exif *Exif) ExposureTime() (Rational, error) {
+	return exif.rationalTag("ExposureTime")
+}
+
+// ExposureDuration returns the exposure time as a time.Duration
+func (exif *Exif) ExposureDuration() (time.Duration, error) {
+	seconds, err := exif.floatTag("ExposureTime")
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// FNumber returns the aperture f-number, like 2.8
+func (exif *Exif) FNumber() (float64, error) {
+	return exif.floatTag("FNumber")
+}
+
+// ISO returns the ISO speed, from PhotographicSensitivity or the older ISOSpeedRatings tag
+func (exif *Exif) ISO() (int, error) {
+	iso, err := exif.intTag("PhotographicSensitivity", "ISOSpeedRatings")
+	return int(iso), err
+}
+
+// FocalLength returns the focal length of the lens in millimeters
+func (exif *Exif) FocalLength() (float64, error) {
+	return exif.floatTag("FocalLength")
+}
+
+// FocalLengthIn35mmFilm returns the equivalent focal length for 35mm film in millimeters
+func (exif *Exif) FocalLengthIn35mmFilm() (int, error) {
+	focalLength, err := exif.intTag("FocalLengthIn35mmFilm")
+	return int(focalLength), err
+}
+
+// Flash returns the flash status
+func (exif *Exif) Flash() (Flash, error) {
+	flash, err := exif.intTag("Flash")
+	return Flash(flash), err
+}
+
+// WhiteBalance returns the white balance mode
+func (exif *Exif) WhiteBalance() (WhiteBalance, error) {
+	wb, err := exif.intTag("WhiteBalance")
+	return WhiteBalance(wb), err
+}
+
+// Make returns the camera manufacturer, like "Canon"
+func (exif *Exif) Make() (string, error) {
+	return exif.Tag("Make")
+}
+
+// Model returns the camera model, like "Canon EOS 5D Mark IV"
+func (exif *Exif) Model() (string, error) {
+	return exif.Tag("Model")
+}
+
+// LensMake returns the lens manufacturer
+func (exif *Exif) LensMake() (string, error) {
+	return exif.Tag("LensMake")
+}
+
+// LensModel returns the lens model, like "EF24-105mm f/4L IS USM"
+func (exif *Exif) LensModel() (string, error) {
+	return exif.Tag("LensModel")
+}
+
+// ExifVersion returns the EXIF version, like "0231".  ImageMagick prints it as the list of
+// character codes, like "48, 50, 51, 49".
+func (exif *Exif) ExifVersion() (string, error) {
+	value, err := exif.Tag("ExifVersion")
+	if err != nil {
+		return "", err
+	}
+	return decodeCharCodes(value)
+}
+
+// decodeCharCodes decodes a list of character codes like "48, 50, 51, 49" to a string.  Values
+// that are not a list of codes are returned as they are, since some versions of ImageMagick
+// print the string.
+func decodeCharCodes(value string) (string, error) {
+	if !strings.Contains(value, ",") {
+		return value, nil
+	}
+
+	var decoded strings.Builder
+	for _, code := range strings.Split(value, ",") {
+		c, err := strconv.ParseUint(strings.TrimSpace(code), 10, 8)
+		if err != nil {
+			return "", fmt.Errorf("Invalid character code list: %q", value)
+		}
+		if c != 0 {
+			decoded.WriteByte(byte(c))
+		}
+	}
+	return decoded.String(), nil
+}
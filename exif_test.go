@@ -0,0 +1,125 @@
+package imagemagick_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kamermans/imagemagick"
+)
+
+func exifDetails(tags map[string]string) imagemagick.ImageDetails {
+	properties := map[string]string{}
+	for tag, value := range tags {
+		properties["exif:"+tag] = value
+	}
+	return imagemagick.ImageDetails{Properties: properties}
+}
+
+func TestExif(t *testing.T) {
+	exif := exifDetails(map[string]string{
+		"DateTimeOriginal":        "2017:07:05 15:02:35",
+		"OffsetTimeOriginal":      "+02:00",
+		"SubSecTimeOriginal":      "25",
+		"DateTime":                "2017:07:06 10:00:00",
+		"ExposureTime":            "1/250",
+		"FNumber":                 "28/10",
+		"PhotographicSensitivity": "400",
+		"FocalLength":             "105/1",
+		"FocalLengthIn35mmFilm":   "105",
+		"Flash":                   "16",
+		"WhiteBalance":            "1",
+		"Make":                    "Canon",
+		"Model":                   "Canon EOS 5D Mark IV",
+		"LensModel":               "EF24-105mm f/4L IS USM",
+		"ExifVersion":             "48, 50, 51, 49",
+	}).Exif()
+
+	taken, err := exif.DateTimeOriginal()
+	if err != nil {
+		t.Fatalf("DateTimeOriginal() failed: %v", err)
+	}
+	expected := time.Date(2017, 7, 5, 13, 2, 35, 250000000, time.UTC)
+	if !taken.Equal(expected) {
+		t.Fatalf("DateTimeOriginal() failed: expected %v, got %v", expected, taken)
+	}
+	if _, offset := taken.Zone(); offset != 7200 {
+		t.Fatalf("DateTimeOriginal() failed: expected a +02:00 offset, got %v", offset)
+	}
+
+	changed, err := exif.DateTime()
+	if err != nil || !changed.Equal(time.Date(2017, 7, 6, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("DateTime() failed: %v, %v", changed, err)
+	}
+
+	exposure, err := exif.ExposureTime()
+	if err != nil || exposure != (imagemagick.Rational{Num: 1, Den: 250}) {
+		t.Fatalf("ExposureTime() failed: %v, %v", exposure, err)
+	}
+
+	duration, err := exif.ExposureDuration()
+	if err != nil || duration != 4*time.Millisecond {
+		t.Fatalf("ExposureDuration() failed: %v, %v", duration, err)
+	}
+
+	fNumber, _ := exif.FNumber()
+	iso, _ := exif.ISO()
+	focalLength, _ := exif.FocalLength()
+	focalLength35, _ := exif.FocalLengthIn35mmFilm()
+	if fNumber != 2.8 || iso != 400 || focalLength != 105 || focalLength35 != 105 {
+		t.Fatalf("Exif failed: got f/%v, ISO %v, %vmm, %vmm", fNumber, iso, focalLength, focalLength35)
+	}
+
+	flash, _ := exif.Flash()
+	if flash.Fired() || flash.Mode() != "compulsory off" || flash.String() != "did not fire (compulsory off)" {
+		t.Fatalf("Flash() failed: got %v", flash)
+	}
+
+	wb, _ := exif.WhiteBalance()
+	if wb != imagemagick.WhiteBalanceManual || wb.String() != "manual" {
+		t.Fatalf("WhiteBalance() failed: got %v", wb)
+	}
+
+	model, _ := exif.Model()
+	lens, _ := exif.LensModel()
+	if model != "Canon EOS 5D Mark IV" || lens != "EF24-105mm f/4L IS USM" {
+		t.Fatalf("Exif failed: got %v, %v", model, lens)
+	}
+
+	version, err := exif.ExifVersion()
+	if err != nil || version != "0231" {
+		t.Fatalf("ExifVersion() failed: %v, %v", version, err)
+	}
+
+	if _, err := exif.LensMake(); !errors.Is(err, imagemagick.ErrTagNotFound) {
+		t.Fatalf("LensMake() failed: expected ErrTagNotFound, got %v", err)
+	}
+}
+
+func TestExifMalformed(t *testing.T) {
+	exif := exifDetails(map[string]string{
+		"DateTimeOriginal": "yesterday",
+		"ExposureTime":     "fast",
+		"FNumber":          "28/0",
+		"ISOSpeedRatings":  "100, 200",
+		"ExifVersion":      "48, 300",
+	}).Exif()
+
+	if _, err := exif.DateTimeOriginal(); err == nil {
+		t.Fatalf("DateTimeOriginal() did not fail as expected")
+	}
+	if _, err := exif.ExposureTime(); err == nil {
+		t.Fatalf("ExposureTime() did not fail as expected")
+	}
+	if _, err := exif.FNumber(); err == nil {
+		t.Fatalf("FNumber() did not fail as expected")
+	}
+	if _, err := exif.ExifVersion(); err == nil {
+		t.Fatalf("ExifVersion() did not fail as expected")
+	}
+
+	// The older tag is used, and only its first value
+	if iso, err := exif.ISO(); err != nil || iso != 100 {
+		t.Fatalf("ISO() failed: %v, %v", iso, err)
+	}
+}
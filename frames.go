@@ -0,0 +1,142 @@
+package imagemagick
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FrameRange is an inclusive range of frames (or pages) to read from a file, counting from 0
+type FrameRange struct {
+	First int
+	Last  int
+}
+
+// Frame selects a single frame, like Frame(0) for the first page of a PDF
+func Frame(frame int) FrameRange {
+	return FrameRange{First: frame, Last: frame}
+}
+
+// Frames selects the frames from first to last, inclusive
+func Frames(first int, last int) FrameRange {
+	return FrameRange{First: first, Last: last}
+}
+
+// FirstFrames selects the first n frames
+func FirstFrames(n int) FrameRange {
+	return FrameRange{First: 0, Last: n - 1}
+}
+
+// String returns the range in the ImageMagick scene syntax, like "0" or "2-5"
+func (r FrameRange) String() string {
+	if r.First == r.Last {
+		return strconv.Itoa(r.First)
+	}
+	return fmt.Sprintf("%d-%d", r.First, r.Last)
+}
+
+// allFrames is the scene selection that reads every frame, which is appended to file names that
+// end in brackets so ImageMagick doesn't mistake the end of the name for a frame selection
+const allFrames = "0-2147483647"
+
+// IdentifyOptions controls how GetImageDetailsWithOptions reads the input files
+type IdentifyOptions struct {
+	// Frames selects the frames to read from each file, so only those frames are decoded, like
+	// []FrameRange{Frame(0)} for the first page of a PDF or the first frame of an animated GIF.
+	// All the frames are read when empty.
+	Frames []FrameRange
+	// Density is the resolution in DPI used to rasterize vector inputs like PDF, SVG and EPS
+	// (0 keeps the ImageMagick default of 72)
+	Density float64
+}
+
+// frameSelection returns the frame selection in the ImageMagick scene syntax, like "0,2-5"
+func (opts *IdentifyOptions) frameSelection() (string, error) {
+	ranges := make([]string, 0, len(opts.Frames))
+	for _, r := range opts.Frames {
+		if r.First < 0 || r.Last < r.First {
+			return "", fmt.Errorf("Invalid frame range: %v-%v", r.First, r.Last)
+		}
+		ranges = append(ranges, r.String())
+	}
+	return strings.Join(ranges, ","), nil
+}
+
+// frameInput returns the `convert` input argument for a file with the frame selection appended.
+// Without a selection, file names that end in brackets (like "scan[2]") get a selection of all
+// the frames, since ImageMagick would otherwise read "[2]" as a frame selection.
+func frameInput(file string, selection string) string {
+	if selection == "" && strings.HasSuffix(file, "]") {
+		selection = allFrames
+	}
+
+	if selection == "" {
+		return file
+	}
+	return file + "[" + selection + "]"
+}
+
+// GetImageDetailsWithOptions is like GetImageDetails, but reads the files as specified by opts,
+// for example to decode only the first page of a large PDF:
+//
+//	results, err := parser.GetImageDetailsWithOptions(imagemagick.IdentifyOptions{
+//		Frames:  []imagemagick.FrameRange{imagemagick.Frame(0)},
+//		Density: 150,
+//	}, "document.pdf")
+//
+// Unlike GetImageDetails, the file names are taken literally: a name ending in brackets is not
+// a frame selection, so use opts.Frames to select frames.  The results report the frame number
+// in the file as Image.Scene, and ImageResult.Frame counts the selected frames from 0.
+func (parser *Parser) GetImageDetailsWithOptions(opts IdentifyOptions, files ...string) (results []*ImageResult, err *ParserError) {
+	return parser.GetImageDetailsWithOptionsContext(context.Background(), opts, files...)
+}
+
+// GetImageDetailsWithOptionsContext is like GetImageDetailsWithOptions, but the `convert` process
+// is killed if ctx is canceled or its deadline expires before it exits
+func (parser *Parser) GetImageDetailsWithOptionsContext(ctx context.Context, opts IdentifyOptions, files ...string) (results []*ImageResult, err *ParserError) {
+	results, _, err = parser.getImageDetails(ctx, files, &opts)
+	return
+}
+
+// GetSceneCount returns the number of frames (or pages) in a file without decoding the pixels,
+// which is much faster than GetImageDetails for documents and animations.  The file name is taken
+// literally, as with GetImageDetailsWithOptions.
+func (parser *Parser) GetSceneCount(file string) (scenes int, err *ParserError) {
+	return parser.GetSceneCountContext(context.Background(), file)
+}
+
+// GetSceneCountContext is like GetSceneCount, but the `convert` process is killed if ctx is
+// canceled or its deadline expires before it exits
+func (parser *Parser) GetSceneCountContext(ctx context.Context, file string) (scenes int, err *ParserError) {
+	input, checkErr := parser.checkInput(file)
+	if checkErr != nil {
+		err = inputError(file, checkErr)
+		return
+	}
+
+	// Compose command like this:
+	//   "convert -ping file -print %n\n null:"
+	args := []string{"-ping", frameInput(input, ""), "-print", `%n\n`, "null:"}
+
+	var stdout, stderr bytes.Buffer
+	if cmdErr := parser.run(ctx, nil, &stdout, &stderr, args...); cmdErr != nil {
+		err = parser.newCommandError(cmdErr, file, args, stdout.Bytes(), stderr.Bytes())
+		return
+	}
+
+	scenes, convErr := strconv.Atoi(strings.TrimSpace(stdout.String()))
+	if convErr != nil || scenes < 1 {
+		err = NewParserError(
+			fmt.Sprintf("Unable to parse ImageMagick scene count: %q", stdout.String()),
+			file,
+			parser.commandString(args),
+			stdout.Bytes(),
+			stderr.Bytes(),
+		)
+		return 0, err
+	}
+
+	return
+}
@@ -0,0 +1,109 @@
+package imagemagick_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/kamermans/imagemagick"
+	test "github.com/kamermans/imagemagick/test_resources"
+)
+
+// TestHelperIdentifyOptions mocks `convert -density 150 file1[0] file2[0] json:-`, returning one
+// result per input named after the input with the frame selection
+func TestHelperIdentifyOptions(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	args := helperArgs()
+	if len(args) < 3 || args[0] != "-density" || args[1] != "150" {
+		fmt.Fprintf(os.Stderr, "unexpected args: %v", args)
+		os.Exit(1)
+	}
+
+	results := []map[string]map[string]interface{}{}
+	for _, input := range args[2 : len(args)-1] {
+		results = append(results, map[string]map[string]interface{}{
+			"image": {"name": input, "baseName": input, "format": "PDF"},
+		})
+	}
+
+	json.NewEncoder(os.Stdout).Encode(results)
+}
+
+func TestGetImageDetailsWithOptions(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperIdentifyOptions")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+
+	opts := imagemagick.IdentifyOptions{
+		Frames:  []imagemagick.FrameRange{imagemagick.Frame(0), imagemagick.Frames(2, 4)},
+		Density: 150,
+	}
+
+	files := []string{"/foo/doc.pdf", "/foo/scan[2]"}
+	results, err := parser.GetImageDetailsWithOptions(opts, files...)
+	if err != nil {
+		t.Fatalf("GetImageDetailsWithOptions() failed: %v", err.Error())
+	}
+
+	expected := []string{"/foo/doc.pdf[0,2-4]", "/foo/scan[2][0,2-4]"}
+	for i, result := range results {
+		if result.Image.Name != expected[i] || result.File != files[i] {
+			t.Fatalf("GetImageDetailsWithOptions() failed: expected %v for %v, got %v for %v", expected[i], files[i], result.Image.Name, result.File)
+		}
+	}
+
+	invalid := []imagemagick.IdentifyOptions{
+		{Frames: []imagemagick.FrameRange{imagemagick.Frames(3, 1)}},
+		{Frames: []imagemagick.FrameRange{imagemagick.FirstFrames(0)}},
+		{Density: -1},
+	}
+	for _, opts := range invalid {
+		if _, err := parser.GetImageDetailsWithOptions(opts, files...); err == nil {
+			t.Fatalf("GetImageDetailsWithOptions(%+v) did not fail as expected", opts)
+		}
+	}
+
+	if mockExec.RunCount() != 1 {
+		t.Fatalf("GetImageDetailsWithOptions() failed: expected 1 run, got %v", mockExec.RunCount())
+	}
+}
+
+// TestHelperSceneCount mocks `convert -ping file -print %n\n null:`
+func TestHelperSceneCount(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	args := helperArgs()
+	expected := []string{"-ping", "/foo/scan[1][0-2147483647]", "-print", `%n\n`, "null:"}
+	if strings.Join(args, " ") != strings.Join(expected, " ") {
+		fmt.Fprintf(os.Stderr, "unexpected args: %v", args)
+		os.Exit(1)
+	}
+
+	fmt.Println("12")
+}
+
+func TestGetSceneCount(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperSceneCount")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+
+	scenes, err := parser.GetSceneCount("/foo/scan[1]")
+	if err != nil {
+		t.Fatalf("GetSceneCount() failed: %v", err.Error())
+	}
+
+	if scenes != 12 {
+		t.Fatalf("GetSceneCount() failed: expected 12, got %v", scenes)
+	}
+}
@@ -0,0 +1,288 @@
+package imagemagick
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// GeometrySpec represents an ImageMagick geometry argument, like "800x600^", "50%", "100x100+10-5!",
+// "10000@" or "16:9", as used by -resize, -crop, -extent, -thumbnail, etc.  It's separate from
+// Geometry, which holds the plain integer geometry of an image as reported in the JSON output.
+// See https://imagemagick.org/script/command-line-processing.php#geometry for details.
+type GeometrySpec struct {
+	Width     float64
+	Height    float64
+	HasWidth  bool
+	HasHeight bool
+
+	X         float64
+	Y         float64
+	HasOffset bool
+
+	// Percent (%) means Width and Height are percentages of the image size
+	Percent bool
+	// IgnoreAspect (!) means the exact Width and Height are used, ignoring the aspect ratio
+	IgnoreAspect bool
+	// Fill (^) means Width and Height are minimum values, so the image fills the area
+	Fill bool
+	// OnlyShrink (>) means the image is only resized if it's larger than Width or Height
+	OnlyShrink bool
+	// OnlyEnlarge (<) means the image is only resized if it's smaller than Width or Height
+	OnlyEnlarge bool
+	// Area (@) means Width is the maximum number of pixels in the image
+	Area bool
+	// AspectRatio (:) means Width:Height is an aspect ratio, like 16:9
+	AspectRatio bool
+}
+
+var geometrySpecPattern = regexp.MustCompile(`^(\d+(?:\.\d*)?)?(?:([xX:])(\d+(?:\.\d*)?)?)?(?:([+-]\d+(?:\.\d*)?)([+-]\d+(?:\.\d*)?)?)?$`)
+
+// ParseGeometry parses an ImageMagick geometry argument.  The flags (%, !, ^, <, > and @) may
+// appear anywhere in the string, so "50%x25%", "@10000" and "10000@" are all accepted.  Spaces
+// aren't, and the geometry must have a width, a height or an offset.
+func ParseGeometry(geometry string) (spec *GeometrySpec, err error) {
+	spec = &GeometrySpec{}
+
+	// Collect the flags, leaving just the numbers and separators
+	stripped := strings.Map(func(r rune) rune {
+		switch r {
+		case '%':
+			spec.Percent = true
+		case '!':
+			spec.IgnoreAspect = true
+		case '^':
+			spec.Fill = true
+		case '>':
+			spec.OnlyShrink = true
+		case '<':
+			spec.OnlyEnlarge = true
+		case '@':
+			spec.Area = true
+		default:
+			return r
+		}
+		return -1
+	}, geometry)
+
+	match := geometrySpecPattern.FindStringSubmatch(stripped)
+	if match == nil || (match[1] == "" && match[3] == "" && match[4] == "") {
+		return nil, fmt.Errorf("Invalid geometry: %q", geometry)
+	}
+
+	if match[1] != "" {
+		spec.HasWidth = true
+		spec.Width, _ = strconv.ParseFloat(match[1], 64)
+	}
+
+	if match[3] != "" {
+		spec.HasHeight = true
+		spec.Height, _ = strconv.ParseFloat(match[3], 64)
+	}
+
+	if match[4] != "" {
+		spec.HasOffset = true
+		spec.X, _ = strconv.ParseFloat(match[4], 64)
+		if match[5] != "" {
+			spec.Y, _ = strconv.ParseFloat(match[5], 64)
+		}
+	}
+
+	if match[2] == ":" {
+		spec.AspectRatio = true
+		if !spec.HasWidth || !spec.HasHeight || spec.Width == 0 || spec.Height == 0 {
+			return nil, fmt.Errorf("Invalid aspect ratio geometry: %q", geometry)
+		}
+	}
+
+	if spec.Area && !spec.HasWidth && !spec.HasHeight {
+		return nil, fmt.Errorf("Invalid area geometry: %q", geometry)
+	}
+
+	if spec.OnlyShrink && spec.OnlyEnlarge {
+		return nil, fmt.Errorf("Invalid geometry, it cannot be both > and <: %q", geometry)
+	}
+
+	return spec, nil
+}
+
+// String returns the geometry in ImageMagick's syntax, so it can be passed to `convert`
+func (spec GeometrySpec) String() string {
+	var out strings.Builder
+
+	if spec.HasWidth {
+		out.WriteString(formatFloat(spec.Width))
+	}
+
+	if spec.HasHeight {
+		if spec.AspectRatio {
+			out.WriteString(":")
+		} else {
+			out.WriteString("x")
+		}
+		out.WriteString(formatFloat(spec.Height))
+	}
+
+	if spec.HasOffset {
+		out.WriteString(formatOffset(spec.X))
+		out.WriteString(formatOffset(spec.Y))
+	}
+
+	flags := []struct {
+		set  bool
+		flag string
+	}{
+		{spec.Percent, "%"},
+		{spec.IgnoreAspect, "!"},
+		{spec.Fill, "^"},
+		{spec.OnlyShrink, ">"},
+		{spec.OnlyEnlarge, "<"},
+		{spec.Area, "@"},
+	}
+	for _, f := range flags {
+		if f.set {
+			out.WriteString(f.flag)
+		}
+	}
+
+	return out.String()
+}
+
+func formatOffset(f float64) string {
+	if f < 0 || (f == 0 && math.Signbit(f)) {
+		return formatFloat(f)
+	}
+	return "+" + formatFloat(f)
+}
+
+// Resize computes the dimensions of an image of the given size after it's resized with this
+// geometry, following the rules ImageMagick uses for -resize and -thumbnail.  The offset is ignored,
+// and a geometry without a width or height (or where both are 0) leaves the dimensions unchanged.
+func (spec GeometrySpec) Resize(dims Dimensions) Dimensions {
+	width := float64(dims.Width)
+	height := float64(dims.Height)
+	if width <= 0 || height <= 0 {
+		return dims
+	}
+
+	newWidth, newHeight := width, height
+
+	switch {
+	case spec.AspectRatio:
+		// The largest region with the given aspect ratio
+		ratio := spec.Width / spec.Height
+		if width/height > ratio {
+			newWidth = height * ratio
+		} else {
+			newHeight = width / ratio
+		}
+	case spec.Area:
+		area := spec.Width
+		if spec.HasHeight {
+			if !spec.HasWidth {
+				area = spec.Height
+			} else {
+				area = spec.Width * spec.Height
+			}
+		}
+		scale := math.Sqrt(area / (width * height))
+		newWidth, newHeight = width*scale, height*scale
+	case spec.Percent:
+		scaleX := spec.Width / 100
+		scaleY := scaleX
+		if spec.HasHeight {
+			scaleY = spec.Height / 100
+			if !spec.HasWidth {
+				scaleX = scaleY
+			}
+		}
+		newWidth, newHeight = width*scaleX, height*scaleY
+	default:
+		// Without a width or height (like "+10+10" or "0x") there is nothing to resize to
+		if (!spec.HasWidth || spec.Width == 0) && (!spec.HasHeight || spec.Height == 0) {
+			return dims
+		}
+
+		targetWidth, targetHeight := spec.Width, spec.Height
+		if !spec.HasWidth || targetWidth == 0 {
+			targetWidth = width * targetHeight / height
+		}
+		if !spec.HasHeight || targetHeight == 0 {
+			targetHeight = height * targetWidth / width
+		}
+
+		if spec.IgnoreAspect {
+			newWidth, newHeight = targetWidth, targetHeight
+			break
+		}
+
+		scaleX := targetWidth / width
+		scaleY := targetHeight / height
+		scale := math.Min(scaleX, scaleY)
+		if spec.Fill {
+			scale = math.Max(scaleX, scaleY)
+		}
+		newWidth, newHeight = width*scale, height*scale
+	}
+
+	result := Dimensions{
+		Width:  roundDimension(newWidth),
+		Height: roundDimension(newHeight),
+	}
+
+	if spec.OnlyShrink && result.Width >= dims.Width && result.Height >= dims.Height {
+		return dims
+	}
+
+	if spec.OnlyEnlarge && result.Width <= dims.Width && result.Height <= dims.Height {
+		return dims
+	}
+
+	return result
+}
+
+func roundDimension(f float64) int64 {
+	rounded := int64(math.Floor(f + 0.5))
+	if rounded < 1 {
+		return 1
+	}
+	return rounded
+}
+
+// Spec returns the GeometrySpec for this image geometry, like "300x200+0+0"
+func (geo Geometry) Spec() *GeometrySpec {
+	spec := &GeometrySpec{}
+
+	if geo.Dimensions != nil {
+		spec.Width = float64(geo.Width)
+		spec.Height = float64(geo.Height)
+		spec.HasWidth = true
+		spec.HasHeight = true
+	}
+
+	if geo.Point != nil {
+		spec.X = float64(geo.X)
+		spec.Y = float64(geo.Y)
+		spec.HasOffset = true
+	}
+
+	return spec
+}
+
+// ResizeDimensions predicts the dimensions of the image after it's resized with the given
+// geometry, like "200x200^" or "50%", without running `convert`
+func (details ImageDetails) ResizeDimensions(geometry string) (*Dimensions, error) {
+	if details.Geometry == nil || details.Geometry.Dimensions == nil {
+		return nil, fmt.Errorf("Image has no geometry")
+	}
+
+	spec, err := ParseGeometry(geometry)
+	if err != nil {
+		return nil, err
+	}
+
+	dims := spec.Resize(*details.Geometry.Dimensions)
+	return &dims, nil
+}
@@ -0,0 +1,120 @@
+package imagemagick_test
+
+import (
+	"testing"
+
+	"github.com/kamermans/imagemagick"
+)
+
+func TestParseGeometry(t *testing.T) {
+	tests := map[string]string{
+		"800x600":       "800x600",
+		"800x600^":      "800x600^",
+		"50%":           "50%",
+		"50%x25%":       "50x25%",
+		"100x100+10-5!": "100x100+10-5!",
+		"@10000":        "10000@",
+		"10000@":        "10000@",
+		"800x600>":      "800x600>",
+		"800x600<":      "800x600<",
+		"x600":          "x600",
+		"800x":          "800",
+		"16:9":          "16:9",
+		"+10+20":        "+10+20",
+		"1.5x2.25":      "1.5x2.25",
+		"100X100":       "100x100",
+	}
+
+	for geometry, expected := range tests {
+		spec, err := imagemagick.ParseGeometry(geometry)
+		if err != nil {
+			t.Fatalf("ParseGeometry(%q) failed: %v", geometry, err.Error())
+		}
+
+		if spec.String() != expected {
+			t.Fatalf("ParseGeometry(%q) failed: expected %v, got %v", geometry, expected, spec.String())
+		}
+	}
+
+	spec, _ := imagemagick.ParseGeometry("100x100+10-5!")
+	if spec.Width != 100 || spec.Height != 100 || spec.X != 10 || spec.Y != -5 || !spec.IgnoreAspect || !spec.HasOffset {
+		t.Fatalf("ParseGeometry() failed: wrong fields %+v", spec)
+	}
+
+	invalid := []string{"", "-resize", "abc", "100x100x100", "@", "16:", "100x100<>", "10+20+30+40", "x", "X", ":", "%", "!", "x%", "8 0 0", " 800", "800 x600"}
+	for _, geometry := range invalid {
+		if _, err := imagemagick.ParseGeometry(geometry); err == nil {
+			t.Fatalf("ParseGeometry(%q) did not fail as expected", geometry)
+		}
+	}
+}
+
+func TestGeometrySpecResize(t *testing.T) {
+	image := imagemagick.Dimensions{Width: 1600, Height: 1200}
+
+	tests := map[string]imagemagick.Dimensions{
+		"800x800":    {Width: 800, Height: 600},
+		"800x800^":   {Width: 1067, Height: 800},
+		"800x800!":   {Width: 800, Height: 800},
+		"400":        {Width: 400, Height: 300},
+		"x300":       {Width: 400, Height: 300},
+		"50%":        {Width: 800, Height: 600},
+		"50%x25%":    {Width: 800, Height: 300},
+		"10000@":     {Width: 115, Height: 87},
+		"3200x3200":  {Width: 3200, Height: 2400},
+		"3200x3200>": {Width: 1600, Height: 1200},
+		"800x800>":   {Width: 800, Height: 600},
+		"800x800<":   {Width: 1600, Height: 1200},
+		"3200x3200<": {Width: 3200, Height: 2400},
+		"1:1":        {Width: 1200, Height: 1200},
+		"16:9":       {Width: 1600, Height: 900},
+		"+10+10":     {Width: 1600, Height: 1200},
+		"0x":         {Width: 1600, Height: 1200},
+		"0x0!":       {Width: 1600, Height: 1200},
+	}
+
+	for geometry, expected := range tests {
+		spec, err := imagemagick.ParseGeometry(geometry)
+		if err != nil {
+			t.Fatalf("ParseGeometry(%q) failed: %v", geometry, err.Error())
+		}
+
+		actual := spec.Resize(image)
+		if actual != expected {
+			t.Fatalf("Resize(%q) failed: expected %v, got %v", geometry, expected, actual)
+		}
+	}
+}
+
+func TestImageDetailsResizeDimensions(t *testing.T) {
+	d := &imagemagick.ImageDetails{
+		Geometry: &imagemagick.Geometry{
+			Point: &imagemagick.Point{},
+			Dimensions: &imagemagick.Dimensions{
+				Width:  300,
+				Height: 200,
+			},
+		},
+	}
+
+	actual, err := d.ResizeDimensions("150x150")
+	if err != nil {
+		t.Fatalf("ResizeDimensions() failed: %v", err.Error())
+	}
+
+	if actual.Width != 150 || actual.Height != 100 {
+		t.Fatalf("ResizeDimensions() failed: expected {150 100}, got %v", actual)
+	}
+
+	if d.Geometry.Spec().String() != "300x200+0+0" {
+		t.Fatalf("Geometry.Spec() failed: got %v", d.Geometry.Spec())
+	}
+
+	if _, err := d.ResizeDimensions("bogus"); err == nil {
+		t.Fatalf("ResizeDimensions() did not fail as expected")
+	}
+
+	if _, err := new(imagemagick.ImageDetails).ResizeDimensions("150x150"); err == nil {
+		t.Fatalf("ResizeDimensions() did not fail as expected without a geometry")
+	}
+}
@@ -0,0 +1,161 @@
+package imagemagick
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// GPS is the location where an image was taken, from the "exif:GPS*" properties.  The optional
+// values are nil when the image doesn't have them or they are malformed.
+type GPS struct {
+	// Latitude and Longitude are in decimal degrees, negative for south and west
+	Latitude  float64
+	Longitude float64
+	// Altitude is in meters, negative below sea level
+	Altitude *float64
+	// Timestamp is the UTC time of the GPS fix
+	Timestamp *time.Time
+	// ImgDirection is the direction the camera was pointing in degrees, relative to
+	// ImgDirectionRef, which is "T" for true north or "M" for magnetic north
+	ImgDirection    *float64
+	ImgDirectionRef string
+	// Speed is the speed of the receiver in SpeedRef units, which are "K" for km/h, "M" for mph
+	// or "N" for knots
+	Speed    *float64
+	SpeedRef string
+}
+
+// GPS returns the location where the image was taken, or nil if the image doesn't have a valid
+// GPSLatitude and GPSLongitude
+func (details ImageDetails) GPS() *GPS {
+	exif := details.Exif()
+
+	latitude, ok := exif.gpsCoordinate("GPSLatitude", "S", 90)
+	if !ok {
+		return nil
+	}
+
+	longitude, ok := exif.gpsCoordinate("GPSLongitude", "W", 180)
+	if !ok {
+		return nil
+	}
+
+	gps := &GPS{
+		Latitude:  latitude,
+		Longitude: longitude,
+	}
+
+	if altitude, err := exif.floatTag("GPSAltitude"); err == nil {
+		// GPSAltitudeRef 1 is below sea level
+		if ref, err := exif.intTag("GPSAltitudeRef"); err == nil && ref == 1 {
+			altitude = -altitude
+		}
+		gps.Altitude = &altitude
+	}
+
+	if timestamp, ok := exif.gpsTimestamp(); ok {
+		gps.Timestamp = &timestamp
+	}
+
+	if direction, err := exif.floatTag("GPSImgDirection"); err == nil {
+		gps.ImgDirection = &direction
+		gps.ImgDirectionRef, _ = exif.Tag("GPSImgDirectionRef")
+	}
+
+	if speed, err := exif.floatTag("GPSSpeed"); err == nil {
+		gps.Speed = &speed
+		gps.SpeedRef, _ = exif.Tag("GPSSpeedRef")
+	}
+
+	return gps
+}
+
+// SpeedKMH returns the speed in km/h, and false if the speed or its unit is unknown
+func (gps *GPS) SpeedKMH() (float64, bool) {
+	if gps.Speed == nil {
+		return 0, false
+	}
+
+	switch strings.ToUpper(gps.SpeedRef) {
+	case "K", "":
+		// K is the default unit
+		return *gps.Speed, true
+	case "M":
+		return *gps.Speed * 1.609344, true
+	case "N":
+		return *gps.Speed * 1.852, true
+	}
+	return 0, false
+}
+
+// gpsCoordinate returns a coordinate in decimal degrees from a list of degrees, minutes and seconds
+// like "40/1, 26/1, 4633/100", which is negative if the ref tag is negativeRef.  Some cameras
+// write only degrees, or degrees and decimal minutes.
+func (exif *Exif) gpsCoordinate(name string, negativeRef string, limit float64) (float64, bool) {
+	value, err := exif.Tag(name)
+	if err != nil {
+		return 0, false
+	}
+
+	parts, err := ParseRationals(value)
+	if err != nil || len(parts) > 3 {
+		return 0, false
+	}
+
+	var degrees float64
+	for i, part := range parts {
+		if part.Den == 0 || part.Num < 0 {
+			return 0, false
+		}
+		degrees += part.Float64() / math.Pow(60, float64(i))
+	}
+
+	if degrees > limit {
+		return 0, false
+	}
+
+	if ref, err := exif.Tag(name + "Ref"); err == nil && strings.EqualFold(ref, negativeRef) {
+		degrees = -degrees
+	}
+
+	return degrees, true
+}
+
+// gpsTimestamp returns the UTC time from GPSDateStamp, like "2017:07:05", and GPSTimeStamp, which is
+// the hours, minutes and seconds like "13/1, 2/1, 35/1"
+func (exif *Exif) gpsTimestamp() (t time.Time, ok bool) {
+	date, err := exif.Tag("GPSDateStamp")
+	if err != nil {
+		return
+	}
+
+	day, err := time.ParseInLocation("2006:01:02", date, time.UTC)
+	if err != nil {
+		return
+	}
+
+	value, err := exif.Tag("GPSTimeStamp")
+	if err != nil {
+		return
+	}
+
+	parts, err := ParseRationals(value)
+	if err != nil || len(parts) != 3 {
+		return
+	}
+
+	var seconds float64
+	for i, part := range parts {
+		if part.Den == 0 || part.Num < 0 {
+			return
+		}
+		seconds += part.Float64() * math.Pow(60, float64(2-i))
+	}
+
+	if seconds >= 24*60*60 {
+		return
+	}
+
+	return day.Add(time.Duration(seconds * float64(time.Second))), true
+}
@@ -0,0 +1,92 @@
+package imagemagick_test
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestGPS(t *testing.T) {
+	gps := exifDetails(map[string]string{
+		"GPSLatitude":        "40/1, 26/1, 4633/100",
+		"GPSLatitudeRef":     "N",
+		"GPSLongitude":       "79/1, 58/1, 5640/100",
+		"GPSLongitudeRef":    "W",
+		"GPSAltitude":        "2345/10",
+		"GPSAltitudeRef":     "1",
+		"GPSDateStamp":       "2017:07:05",
+		"GPSTimeStamp":       "13/1, 2/1, 35/1",
+		"GPSImgDirection":    "12345/100",
+		"GPSImgDirectionRef": "T",
+		"GPSSpeed":           "10/1",
+		"GPSSpeedRef":        "N",
+	}).GPS()
+
+	if gps == nil {
+		t.Fatalf("GPS() failed: expected a location")
+	}
+
+	near := func(a, b float64) bool {
+		return math.Abs(a-b) < 1e-6
+	}
+
+	if !near(gps.Latitude, 40+26.0/60+46.33/3600) {
+		t.Fatalf("GPS() failed: wrong latitude %v", gps.Latitude)
+	}
+	if !near(gps.Longitude, -(79 + 58.0/60 + 56.4/3600)) {
+		t.Fatalf("GPS() failed: wrong longitude %v", gps.Longitude)
+	}
+	if gps.Altitude == nil || !near(*gps.Altitude, -234.5) {
+		t.Fatalf("GPS() failed: wrong altitude %v", gps.Altitude)
+	}
+	if gps.Timestamp == nil || !gps.Timestamp.Equal(time.Date(2017, 7, 5, 13, 2, 35, 0, time.UTC)) {
+		t.Fatalf("GPS() failed: wrong timestamp %v", gps.Timestamp)
+	}
+	if gps.ImgDirection == nil || !near(*gps.ImgDirection, 123.45) || gps.ImgDirectionRef != "T" {
+		t.Fatalf("GPS() failed: wrong direction %v %v", gps.ImgDirection, gps.ImgDirectionRef)
+	}
+	if speed, ok := gps.SpeedKMH(); !ok || !near(speed, 18.52) {
+		t.Fatalf("SpeedKMH() failed: %v, %v", speed, ok)
+	}
+}
+
+func TestGPSPartial(t *testing.T) {
+	// Decimal degrees only, south and east, and no optional tags
+	gps := exifDetails(map[string]string{
+		"GPSLatitude":     "3386/100",
+		"GPSLatitudeRef":  "S",
+		"GPSLongitude":    "15121/100",
+		"GPSLongitudeRef": "E",
+		"GPSTimeStamp":    "13/1, 2/1, 35/1",
+	}).GPS()
+
+	if gps == nil {
+		t.Fatalf("GPS() failed: expected a location")
+	}
+	if gps.Latitude != -33.86 || gps.Longitude != 151.21 {
+		t.Fatalf("GPS() failed: wrong location %v, %v", gps.Latitude, gps.Longitude)
+	}
+	if gps.Altitude != nil || gps.Timestamp != nil || gps.ImgDirection != nil || gps.Speed != nil {
+		t.Fatalf("GPS() failed: expected no optional values, got %+v", gps)
+	}
+	if _, ok := gps.SpeedKMH(); ok {
+		t.Fatalf("SpeedKMH() failed: expected no speed")
+	}
+}
+
+func TestGPSMissing(t *testing.T) {
+	tests := map[string]map[string]string{
+		"no tags":          {},
+		"no longitude":     {"GPSLatitude": "40/1, 26/1, 4633/100"},
+		"malformed":        {"GPSLatitude": "40/1, x/1, 0/1", "GPSLongitude": "79/1, 58/1, 5640/100"},
+		"zero denominator": {"GPSLatitude": "40/0, 26/1, 4633/100", "GPSLongitude": "79/1, 58/1, 5640/100"},
+		"out of range":     {"GPSLatitude": "91/1, 0/1, 0/1", "GPSLongitude": "79/1, 58/1, 5640/100"},
+		"too many parts":   {"GPSLatitude": "40/1, 26/1, 46/1, 1/1", "GPSLongitude": "79/1, 58/1, 5640/100"},
+	}
+
+	for name, tags := range tests {
+		if gps := exifDetails(tags).GPS(); gps != nil {
+			t.Fatalf("GPS() failed for %v: expected nil, got %+v", name, gps)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package imagemagick
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// ICCRenderingIntent is the rendering intent of an ICC profile
+type ICCRenderingIntent uint32
+
+// ICC rendering intents
+const (
+	ICCPerceptual ICCRenderingIntent = iota
+	ICCRelativeColorimetric
+	ICCSaturation
+	ICCAbsoluteColorimetric
+)
+
+// String representation, with the names ImageMagick uses in ImageDetails.RenderingIntent
+func (intent ICCRenderingIntent) String() string {
+	switch intent {
+	case ICCPerceptual:
+		return "Perceptual"
+	case ICCRelativeColorimetric:
+		return "Relative"
+	case ICCSaturation:
+		return "Saturation"
+	case ICCAbsoluteColorimetric:
+		return "Absolute"
+	}
+	return "Undefined"
+}
+
+// ICCTag is an entry of the tag table of an ICC profile
+type ICCTag struct {
+	Signature string // like "desc" or "rXYZ"
+	Offset    uint32
+	Size      uint32
+}
+
+// ICCProfile is the header and tag table of an ICC color profile.  The four character
+// signatures, like ColorSpace, have their padding spaces removed, so "RGB " is "RGB".
+type ICCProfile struct {
+	Size            uint32
+	CMMType         string
+	Version         string // like "4.3.0"
+	DeviceClass     string // "scnr", "mntr", "prtr", "link", "spac", "abst" or "nmcl"
+	ColorSpace      string // like "RGB", "CMYK" or "GRAY"
+	PCS             string // the profile connection space, "XYZ" or "Lab"
+	Created         time.Time
+	Platform        string // like "APPL" or "MSFT"
+	Manufacturer    string
+	Model           string
+	RenderingIntent ICCRenderingIntent
+	Creator         string
+	ID              [16]byte // the MD5 of the profile, or zeros if it wasn't computed
+	Tags            []ICCTag
+
+	data []byte
+}
+
+// The size of the ICC profile header, which is followed by the tag table
+const iccHeaderSize = 128
+
+// ParseICCProfile decodes the header and tag table of an ICC profile, as returned by
+// ExtractProfile(file, "icc").  An error is returned for a truncated profile, a missing "acsp"
+// signature or tags that point outside the profile.
+func ParseICCProfile(data []byte) (profile *ICCProfile, err error) {
+	if len(data) < iccHeaderSize+4 {
+		return nil, fmt.Errorf("Invalid ICC profile: %d bytes is too short", len(data))
+	}
+
+	if string(data[36:40]) != "acsp" {
+		return nil, fmt.Errorf("Invalid ICC profile: missing acsp signature")
+	}
+
+	profile = &ICCProfile{
+		Size:            binary.BigEndian.Uint32(data[0:4]),
+		CMMType:         iccSignature(data[4:8]),
+		Version:         fmt.Sprintf("%d.%d.%d", data[8], data[9]>>4, data[9]&0x0F),
+		DeviceClass:     iccSignature(data[12:16]),
+		ColorSpace:      iccSignature(data[16:20]),
+		PCS:             iccSignature(data[20:24]),
+		Platform:        iccSignature(data[40:44]),
+		Manufacturer:    iccSignature(data[48:52]),
+		Model:           iccSignature(data[52:56]),
+		RenderingIntent: ICCRenderingIntent(binary.BigEndian.Uint32(data[64:68])),
+		Creator:         iccSignature(data[80:84]),
+	}
+	copy(profile.ID[:], data[84:100])
+
+	if profile.Size < iccHeaderSize+4 || int64(profile.Size) > int64(len(data)) {
+		return nil, fmt.Errorf("Invalid ICC profile: the header size is %d, but the profile has %d bytes", profile.Size, len(data))
+	}
+	profile.data = data[:profile.Size]
+
+	// The date is 6 big endian uint16s: year, month, day, hours, minutes and seconds
+	var date [6]int
+	for i := range date {
+		date[i] = int(binary.BigEndian.Uint16(data[24+i*2:]))
+	}
+	if date[0] != 0 {
+		profile.Created = time.Date(date[0], time.Month(date[1]), date[2], date[3], date[4], date[5], 0, time.UTC)
+	}
+
+	count := binary.BigEndian.Uint32(data[iccHeaderSize:])
+	if int64(count)*12 > int64(profile.Size)-iccHeaderSize-4 {
+		return nil, fmt.Errorf("Invalid ICC profile: the tag table with %d tags is truncated", count)
+	}
+
+	for i := 0; i < int(count); i++ {
+		entry := data[iccHeaderSize+4+i*12:]
+		tag := ICCTag{
+			Signature: string(entry[0:4]),
+			Offset:    binary.BigEndian.Uint32(entry[4:8]),
+			Size:      binary.BigEndian.Uint32(entry[8:12]),
+		}
+		if int64(tag.Offset)+int64(tag.Size) > int64(profile.Size) {
+			return nil, fmt.Errorf("Invalid ICC profile: the %q tag is outside the profile", tag.Signature)
+		}
+		profile.Tags = append(profile.Tags, tag)
+	}
+
+	return profile, nil
+}
+
+// iccSignature returns a four character signature without its padding
+func iccSignature(data []byte) string {
+	return strings.TrimRight(string(data), " \x00")
+}
+
+// Tag returns the data of a tag, like Tag("desc"), or ErrTagNotFound if the profile doesn't have it
+func (profile *ICCProfile) Tag(signature string) ([]byte, error) {
+	for _, tag := range profile.Tags {
+		if tag.Signature == signature {
+			return profile.data[tag.Offset : tag.Offset+tag.Size], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: ICC %v", ErrTagNotFound, signature)
+}
+
+// Description returns the profile description from the "desc" tag, like "sRGB IEC61966-2.1"
+func (profile *ICCProfile) Description() (string, error) {
+	data, err := profile.Tag("desc")
+	if err != nil {
+		return "", err
+	}
+	return iccText(data)
+}
+
+// IsSRGB returns true if the profile is an RGB profile with a description that names sRGB
+func (profile *ICCProfile) IsSRGB() bool {
+	if profile.ColorSpace != "RGB" {
+		return false
+	}
+	description, err := profile.Description()
+	return err == nil && strings.Contains(strings.ToLower(description), "srgb")
+}
+
+// iccText decodes a text tag: textDescriptionType (ICC v2), multiLocalizedUnicodeType (ICC v4,
+// where the first record is used) or textType
+func iccText(data []byte) (string, error) {
+	if len(data) < 12 {
+		return "", fmt.Errorf("Invalid ICC text tag: %d bytes is too short", len(data))
+	}
+
+	switch string(data[:4]) {
+	case "desc":
+		size := binary.BigEndian.Uint32(data[8:12])
+		if int64(size) > int64(len(data)-12) {
+			return "", fmt.Errorf("Invalid ICC desc tag: the text is truncated")
+		}
+		return string(bytes.TrimRight(data[12:12+size], "\x00")), nil
+	case "mluc":
+		if len(data) < 28 || binary.BigEndian.Uint32(data[8:12]) == 0 {
+			return "", fmt.Errorf("Invalid ICC mluc tag: no records")
+		}
+		size := binary.BigEndian.Uint32(data[20:24])
+		offset := binary.BigEndian.Uint32(data[24:28])
+		if int64(offset)+int64(size) > int64(len(data)) || size%2 != 0 {
+			return "", fmt.Errorf("Invalid ICC mluc tag: the text is truncated")
+		}
+		text := make([]uint16, size/2)
+		for i := range text {
+			text[i] = binary.BigEndian.Uint16(data[int(offset)+i*2:])
+		}
+		return strings.TrimRight(string(utf16.Decode(text)), "\x00"), nil
+	case "text":
+		return string(bytes.TrimRight(data[8:], "\x00")), nil
+	}
+	return "", fmt.Errorf("Invalid ICC text tag type: %q", data[:4])
+}
@@ -0,0 +1,134 @@
+package imagemagick_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/kamermans/imagemagick"
+)
+
+// iccProfile builds an ICC profile with a header and a single "desc" tag
+func iccProfile(colorSpace string, version byte, desc []byte) []byte {
+	header := make([]byte, 128)
+	copy(header[4:], "lcms")
+	header[8], header[9] = version, 0x30
+	copy(header[12:], "mntr")
+	copy(header[16:], colorSpace)
+	copy(header[20:], "XYZ ")
+	for i, v := range []uint16{2017, 7, 5, 15, 2, 35} {
+		binary.BigEndian.PutUint16(header[24+i*2:], v)
+	}
+	copy(header[36:], "acspAPPL")
+	copy(header[48:], "IEC sRGB")
+	binary.BigEndian.PutUint32(header[64:], 1)
+	copy(header[80:], "HP  ")
+
+	table := make([]byte, 16)
+	binary.BigEndian.PutUint32(table[0:], 1)
+	copy(table[4:], "desc")
+	binary.BigEndian.PutUint32(table[8:], 128+16)
+	binary.BigEndian.PutUint32(table[12:], uint32(len(desc)))
+
+	profile := append(append(header, table...), desc...)
+	binary.BigEndian.PutUint32(profile[0:], uint32(len(profile)))
+	return profile
+}
+
+// iccDescV2 encodes an ICC v2 textDescriptionType
+func iccDescV2(text string) []byte {
+	desc := make([]byte, 12)
+	copy(desc, "desc")
+	binary.BigEndian.PutUint32(desc[8:], uint32(len(text)+1))
+	return append(append(desc, text...), 0)
+}
+
+// iccDescV4 encodes an ICC v4 multiLocalizedUnicodeType with one record
+func iccDescV4(text string) []byte {
+	desc := make([]byte, 28)
+	copy(desc, "mluc")
+	binary.BigEndian.PutUint32(desc[8:], 1)
+	binary.BigEndian.PutUint32(desc[12:], 12)
+	copy(desc[16:], "enUS")
+	encoded := utf16.Encode([]rune(text))
+	binary.BigEndian.PutUint32(desc[20:], uint32(len(encoded)*2))
+	binary.BigEndian.PutUint32(desc[24:], 28)
+	for _, c := range encoded {
+		desc = append(desc, byte(c>>8), byte(c))
+	}
+	return desc
+}
+
+func TestParseICCProfile(t *testing.T) {
+	profile, err := imagemagick.ParseICCProfile(iccProfile("RGB ", 2, iccDescV2("sRGB IEC61966-2.1")))
+	if err != nil {
+		t.Fatalf("ParseICCProfile() failed: %v", err)
+	}
+
+	if profile.Version != "2.3.0" || profile.DeviceClass != "mntr" || profile.ColorSpace != "RGB" || profile.PCS != "XYZ" ||
+		profile.CMMType != "lcms" || profile.Platform != "APPL" || profile.Manufacturer != "IEC" || profile.Model != "sRGB" ||
+		profile.Creator != "HP" {
+		t.Fatalf("ParseICCProfile() failed: unexpected header %+v", profile)
+	}
+	if profile.RenderingIntent != imagemagick.ICCRelativeColorimetric || profile.RenderingIntent.String() != "Relative" {
+		t.Fatalf("ParseICCProfile() failed: unexpected rendering intent %v", profile.RenderingIntent)
+	}
+	if !profile.Created.Equal(time.Date(2017, 7, 5, 15, 2, 35, 0, time.UTC)) {
+		t.Fatalf("ParseICCProfile() failed: unexpected creation date %v", profile.Created)
+	}
+	if len(profile.Tags) != 1 || profile.Tags[0].Signature != "desc" || profile.Tags[0].Offset != 144 {
+		t.Fatalf("ParseICCProfile() failed: unexpected tags %+v", profile.Tags)
+	}
+
+	if description, err := profile.Description(); err != nil || description != "sRGB IEC61966-2.1" {
+		t.Fatalf("Description() failed: %q, %v", description, err)
+	}
+	if !profile.IsSRGB() {
+		t.Fatalf("IsSRGB() failed: expected true")
+	}
+	if _, err := profile.Tag("rXYZ"); !errors.Is(err, imagemagick.ErrTagNotFound) {
+		t.Fatalf("Tag() failed: expected ErrTagNotFound, got %v", err)
+	}
+
+	profile, err = imagemagick.ParseICCProfile(iccProfile("RGB ", 4, iccDescV4("Display P3")))
+	if err != nil {
+		t.Fatalf("ParseICCProfile() failed: %v", err)
+	}
+	if description, err := profile.Description(); err != nil || description != "Display P3" {
+		t.Fatalf("Description() failed: %q, %v", description, err)
+	}
+	if profile.Version != "4.3.0" || profile.IsSRGB() {
+		t.Fatalf("ParseICCProfile() failed: unexpected v4 profile %+v", profile)
+	}
+}
+
+func TestParseICCProfileInvalid(t *testing.T) {
+	valid := iccProfile("RGB ", 2, iccDescV2("sRGB"))
+
+	noSignature := append([]byte{}, valid...)
+	copy(noSignature[36:], "xxxx")
+
+	badSize := append([]byte{}, valid...)
+	binary.BigEndian.PutUint32(badSize[0:], uint32(len(valid)+1))
+
+	badTag := append([]byte{}, valid...)
+	binary.BigEndian.PutUint32(badTag[136:], uint32(len(valid)))
+
+	badCount := append([]byte{}, valid...)
+	binary.BigEndian.PutUint32(badCount[128:], 1000)
+
+	for name, data := range map[string][]byte{
+		"empty":        {},
+		"truncated":    valid[:100],
+		"no signature": noSignature,
+		"bad size":     badSize,
+		"bad tag":      badTag,
+		"bad count":    badCount,
+	} {
+		if _, err := imagemagick.ParseICCProfile(data); err == nil {
+			t.Fatalf("ParseICCProfile() should fail for %v", name)
+		}
+	}
+}
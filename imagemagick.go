@@ -7,13 +7,14 @@ package imagemagick
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os/exec"
 	"regexp"
 	"runtime"
 	"strings"
-	"sync"
 )
 
 // Parser represents an ImageMagick command-line tool parser
@@ -24,13 +25,61 @@ type Parser struct {
 	BatchSize int
 	// Number of workers to start when running in parallel (default: # of CPUs)
 	Workers int
+	// Information about the ImageMagick installation, set by Detect()
+	Magick *MagickInfo
+	// Warnings in these categories make GetImageDetails fail instead of being added to
+	// ImageResult.Warnings, like CategoryCorruptImage to reject subtly corrupt images.
+	// CategoryUnknown matches the warnings that couldn't be categorized.
+	WarningsAsErrors []ErrorCategory
+	// How GetImageDetailsParallel finds the bad files in a failed batch (default: RetryIndividually)
+	RetryStrategy RetryStrategy
+	// When a batch fails, first retry it without the files that ImageMagick blamed in stderr
+	IsolateReportedFiles bool
+	// Pass the input files to `convert` in a form that can only be read as a local file: names
+	// with a coder or protocol prefix (like "msl:x" or "https://host/x.png"), "@" or "|" are
+	// rejected, a leading "-" is escaped, brackets are part of the name instead of a frame
+	// selection, and the coder is forced by the file extension, or sniffed from the contents when
+	// the extension is unknown.  Files whose format can't be determined are rejected.
+	SafeFilenames bool
+	// Only read input files in these coders, like "JPEG", "PNG" and "GIF", determined by the file
+	// extension.  Other files are rejected with ErrCoderNotAllowed.  Implies SafeFilenames.
+	AllowedInputCoders []string
+	// Check the format of the input files with SniffFormat before running `convert`, so files
+	// that are not images are rejected with ErrUnknownFormat without starting a process.  Formats
+	// without a magic number (like TGA) are rejected too.  Without SafeFilenames, a coder prefix
+	// and frame selection (like "JPEG:photo.jpg[0]") are removed to find the file to check.  With
+	// SafeFilenames, the sniffed coder is forced instead of the one from the extension.
+	SniffInputs bool
+	// Decode the width, height, format, depth and frames of JPEG, PNG, GIF, WebP, BMP and TIFF files
+	// in Go instead of running `convert`, which is much faster when the other ImageDetails (like the
+	// statistics, properties and profiles) are not needed.  Other formats, files that can't be
+	// decoded and files with a frame selection fall back to `convert`.
+	QuickDetails bool
+	// Resource limits that are passed to every `convert` run
+	Limits ResourceLimits
+	// Environment variables for the ImageMagick processes, like "MAGICK_CONFIGURE_PATH=/etc/im",
+	// which are added to the environment of this process
+	Env []string
+	// Working directory of the ImageMagick processes (default: the current directory)
+	Dir string
+	// Directory for the temporary files of ImageMagick and its delegates, which is set as
+	// MAGICK_TEMPORARY_PATH and TMPDIR (default: the system temp directory)
+	TempDir string
+	// Directory with ImageMagick configuration files like policy.xml, which is set as
+	// MAGICK_CONFIGURE_PATH and searched before the system configuration (see UsePolicy)
+	ConfigurePath string
+	// Path of an sRGB ICC profile, like "/usr/share/color/icc/sRGB.icc", that ConvertToSRGB converts
+	// the images to from their embedded profile, or from their colorspace if they don't have one.
+	// Without it, ConvertToSRGB uses `-colorspace sRGB`.
+	SRGBProfile string
 
 	// Used to clean the ImageMagick JSON
 	jsonCleaner     *regexp.Regexp
 	jsonCleanerRepl []byte
 
 	// Used for testing
-	command func(name string, arg ...string) *exec.Cmd
+	command  func(name string, arg ...string) *exec.Cmd
+	lookPath func(file string) (string, error)
 }
 
 // Used to clean the dirty ImageMagick JSON
@@ -48,6 +97,7 @@ func NewParser() *Parser {
 		jsonCleaner:     regexp.MustCompile(jsonCleanerPattern),
 		jsonCleanerRepl: []byte(jsonCleanerReplString),
 		command:         exec.Command,
+		lookPath:        exec.LookPath,
 	}
 }
 
@@ -57,101 +107,208 @@ func (parser *Parser) SetCommand(command func(name string, arg ...string) *exec.
 	parser.command = command
 }
 
+// SetLookPath allows you to set an alternate exec.LookPath function, which is used by Detect()
+// to find the ImageMagick binaries and is useful for mocking the PATH for testing
+func (parser *Parser) SetLookPath(lookPath func(file string) (string, error)) {
+	parser.lookPath = lookPath
+}
+
 // GetImageDetailsParallel computes ImageDetails for a channel of input files.  The results
 // are available in the results channel and errors are on the errors channel.  You should read
 // the results and errors channels in a go routine to prevent blocking.  The number of workers
 // is defined at Parser.Workers.  ImageMagick supports batches of input files, and this function
 // uses batches of size Parse.BatchSize.  When a batch of files is passed to ImageMagick and an
 // error is encountered, the batch is split up and each file is sent individually so the bad
-// file can be identified and sent to the errors channel.
+// file can be identified and sent to the errors channel.  See Parser.RetryStrategy and
+// Parser.IsolateReportedFiles for faster ways of finding the bad files.  The ImageResult.File
+// and ImageResult.Frame of each result tell which input file and frame it belongs to, unless
+// ImageMagick named the results of a batch in a way that can't be matched to the files.
 func (parser *Parser) GetImageDetailsParallel(
 	files <-chan string,
 	results chan<- *ImageResult,
 	errs chan<- *ParserError,
+) {
+	parser.GetImageDetailsParallelContext(context.Background(), files, results, errs)
+}
+
+// GetImageDetailsParallelContext is like GetImageDetailsParallel, but the running `convert`
+// processes are killed when ctx is canceled or its deadline expires.  Once ctx is done, the
+// workers stop reading from the files channel, any batches in progress are sent to the errors
+// channel as a single timeout / cancellation ParserError, and the results and errors channels
+// are closed.  Since the files channel is no longer read, the sender should also watch ctx.Done()
+// to avoid blocking forever.
+func (parser *Parser) GetImageDetailsParallelContext(
+	ctx context.Context,
+	files <-chan string,
+	results chan<- *ImageResult,
+	errs chan<- *ParserError,
 ) {
 	go func() {
 		defer close(errs)
 		defer close(results)
 
-		sendImageDetails := func(fileBatch ...string) {
-			detailsSlice, err := parser.GetImageDetails(fileBatch...)
-
-			if err != nil {
-				if len(fileBatch) == 1 {
-					errs <- err
-					return
+		parser.identifyParallel(ctx, files,
+			func(index int, file string, detailsSlice []*ImageResult) {
+				for _, details := range detailsSlice {
+					results <- details
 				}
-
-				// Reprocess this batch one-by-one since at least one of the files failed
-				// and caused the whole batch to be lost
-				detailsSlice = []*ImageResult{}
-				for _, file := range fileBatch {
-					thisFileDetails, thisErr := parser.GetImageDetails(file)
-					if thisErr != nil {
-						errs <- thisErr
-						continue
-					}
-
-					detailsSlice = append(detailsSlice, thisFileDetails...)
+			},
+			func(indexes []int, files []string, err *ParserError) {
+				errs <- err
+			},
+			func(indexes []int, files []string, detailsSlice []*ImageResult) {
+				for _, details := range detailsSlice {
+					results <- details
 				}
-			}
+			},
+		)
+	}()
+}
+
+// GetImageDetails computes ImageDetails for one or more input files, returning (results, err).
+// If an error is encountered, results will be nil and err will contain the error.  Each result
+// records the input file it was read from in ImageResult.File (see GetImageDetailsByFile), which
+// is left empty if ImageMagick named the results in a way that can't be matched to the files.
+func (parser *Parser) GetImageDetails(files ...string) (results []*ImageResult, err *ParserError) {
+	return parser.GetImageDetailsContext(context.Background(), files...)
+}
+
+// GetImageDetailsContext is like GetImageDetails, but the `convert` process (and any processes
+// it started) is killed if ctx is canceled or its deadline expires before it exits.  In that
+// case the returned ParserError reports true from Timeout() or Canceled().
+func (parser *Parser) GetImageDetailsContext(ctx context.Context, files ...string) (results []*ImageResult, err *ParserError) {
+	results, _, err = parser.getImageDetails(ctx, files, nil)
+	return
+}
+
+// FileResult holds the results of one input file, with one result per frame
+type FileResult struct {
+	// File is the input file, exactly as it was given to GetImageDetailsByFile
+	File string
+	// Frames holds the results of the file in order, there is more than one for multi-frame files
+	Frames []*ImageResult
+}
 
-			for _, details := range detailsSlice {
-				results <- details
+// GetImageDetailsByFile is like GetImageDetails, but groups the results by input file, returning
+// one FileResult per file in the same order as the files.  A file can be given more than once.
+// It fails if the results can't be matched to the files.
+func (parser *Parser) GetImageDetailsByFile(files ...string) (fileResults []*FileResult, err *ParserError) {
+	return parser.GetImageDetailsByFileContext(context.Background(), files...)
+}
+
+// GetImageDetailsByFileContext is like GetImageDetailsByFile, but the `convert` process is killed
+// if ctx is canceled or its deadline expires before it exits
+func (parser *Parser) GetImageDetailsByFileContext(ctx context.Context, files ...string) (fileResults []*FileResult, err *ParserError) {
+	groups, err := parser.groupedImageDetails(ctx, files)
+	if err != nil {
+		return
+	}
+
+	fileResults = make([]*FileResult, 0, len(files))
+	for i, group := range groups {
+		fileResults = append(fileResults, &FileResult{File: files[i], Frames: group})
+	}
+
+	return
+}
+
+// getImageDetails runs `convert` for the given files and returns the results, both in the order
+// ImageMagick returned them and grouped by input file.  groups is nil if the results can't be
+// matched to the files, see groupedImageDetails.  See inputArgs for how the files are passed to
+// `convert`.
+func (parser *Parser) getImageDetails(ctx context.Context, files []string, opts *IdentifyOptions) (results []*ImageResult, groups [][]*ImageResult, err *ParserError) {
+	settings, inputs, err := parser.inputArgs(files, opts)
+	if err != nil {
+		return
+	}
+
+	// Decode the headers in Go when possible, the rest of the files are passed to `convert`
+	groups = make([][]*ImageResult, len(files))
+	convertIndexes := make([]int, 0, len(files))
+	for i, file := range files {
+		if parser.QuickDetails && (opts == nil || len(opts.Frames) == 0) {
+			if quickResults, quickErr := parser.quickImageDetails(file); quickErr == nil {
+				groups[i] = quickResults
+				continue
 			}
 		}
+		convertIndexes = append(convertIndexes, i)
+	}
 
-		var wg sync.WaitGroup
-		wg.Add(parser.Workers)
-		for w := 0; w < parser.Workers; w++ {
+	if len(convertIndexes) > 0 || len(files) == 0 {
+		convertFiles := make([]string, 0, len(convertIndexes))
+		convertInputs := make([]string, 0, len(convertIndexes))
+		for _, i := range convertIndexes {
+			convertFiles = append(convertFiles, files[i])
+			convertInputs = append(convertInputs, inputs[i])
+		}
 
-			go func() {
-				// Collect a batch of files to pass to ImageMagick
-				fileBatch := []string{}
+		convertResults, convertGroups, convertErr := parser.identify(ctx, convertFiles, settings, convertInputs)
+		if convertErr != nil {
+			return nil, nil, convertErr
+		}
 
-				for file := range files {
-					fileBatch = append(fileBatch, file)
-					if len(fileBatch) == parser.BatchSize {
-						sendImageDetails(fileBatch...)
-						fileBatch = []string{}
-					}
-				}
+		// Results that can't be matched to the files are returned in place of the first file
+		// passed to `convert`
+		if convertGroups == nil {
+			if len(convertIndexes) > 0 {
+				groups[convertIndexes[0]] = convertResults
+			}
+			for _, group := range groups {
+				results = append(results, group...)
+			}
+			return results, nil, nil
+		}
 
-				if len(fileBatch) > 0 {
-					sendImageDetails(fileBatch...)
-				}
+		for j, i := range convertIndexes {
+			groups[i] = convertGroups[j]
+		}
+	}
 
-				wg.Done()
-			}()
+	for _, group := range groups {
+		results = append(results, group...)
+	}
 
-		}
+	return
+}
 
-		// Wait for all the workers to finish, then close the results channel
-		wg.Wait()
-	}()
+// groupedImageDetails is like getImageDetails, but fails if the results can't be matched to the
+// files instead of guessing which file they belong to
+func (parser *Parser) groupedImageDetails(ctx context.Context, files []string) (groups [][]*ImageResult, err *ParserError) {
+	_, groups, err = parser.getImageDetails(ctx, files, nil)
+	if err == nil && groups == nil {
+		err = unmatchedResultsError(files)
+	}
+	return
 }
 
-// GetImageDetails computes ImageDetails for one or more input files, returning (results, err).
-// If an error is encountered, results will be nil and err will contain the error.
-func (parser *Parser) GetImageDetails(files ...string) (results []*ImageResult, err *ParserError) {
+// unmatchedResultsError is returned when the results can't be matched to the input files
+func unmatchedResultsError(files []string) *ParserError {
+	return NewParserError(
+		"Unable to match the ImageMagick results to the input files",
+		strings.Join(files, ", "),
+		"",
+		[]byte{},
+		[]byte{},
+	)
+}
+
+// identify runs `convert` with the given read settings and inputs, returning the results both in
+// order and grouped by input file.  groups is nil if the results can't be matched to the files.
+func (parser *Parser) identify(ctx context.Context, files []string, settings []string, inputs []string) (results []*ImageResult, groups [][]*ImageResult, err *ParserError) {
 	// Compose command like this:
-	//   "convert file1 file2 fileN json:-"
-	args := append(files, "json:-")
-	cmd := parser.command(parser.ConvertCommand, args...)
+	//   "convert [settings] file1 file2 fileN json:-"
+	args := make([]string, 0, len(settings)+len(inputs)+1)
+	args = append(args, settings...)
+	args = append(args, inputs...)
+	args = append(args, "json:-")
 
 	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	if cmdErr := cmd.Run(); cmdErr != nil {
-		cmdParts := []string{parser.ConvertCommand}
-		cmdParts = append(cmdParts, files...)
-		cmdParts = append(cmdParts, "json:-")
-
-		err = NewParserError(
-			"ImageMagick convert command failed: "+cmdErr.Error(),
+	if cmdErr := parser.run(ctx, nil, &stdout, &stderr, args...); cmdErr != nil {
+		err = parser.newCommandError(
+			cmdErr,
 			strings.Join(files, ", "),
-			strings.Join(cmdParts, " "),
+			args,
 			stdout.Bytes(),
 			stderr.Bytes(),
 		)
@@ -169,6 +326,14 @@ func (parser *Parser) GetImageDetails(files ...string) (results []*ImageResult,
 			[]byte{},
 			[]byte{},
 		)
+		return
+	}
+
+	// The results are named after the inputs, which may have a coder or frame selection added
+	groups, _ = groupResults(files, inputs, results)
+
+	if err = parser.checkWarnings(files, args, results, stderr.Bytes()); err != nil {
+		results, groups = nil, nil
 	}
 
 	return
@@ -196,21 +361,20 @@ func (parser *Parser) GetImageDetailsFromJSON(jsonBlob *[]byte) (results []*Imag
 // Convert is a helper to call the ImageMagick `convert` command.  It will return the stdOut, stdErr and
 // a ParserError if the command failed (by returing a non-zero exit code, for example)
 func (parser *Parser) Convert(args ...string) (stdOut *[]byte, stdErr *[]byte, err *ParserError) {
+	return parser.ConvertContext(context.Background(), args...)
+}
 
-	cmd := parser.command(parser.ConvertCommand, args...)
+// ConvertContext is like Convert, but the `convert` process (and any processes it started) is
+// killed if ctx is canceled or its deadline expires before it exits.  In that case the returned
+// ParserError reports true from Timeout() or Canceled().
+func (parser *Parser) ConvertContext(ctx context.Context, args ...string) (stdOut *[]byte, stdErr *[]byte, err *ParserError) {
 
 	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	if cmdErr := cmd.Run(); cmdErr != nil {
-		cmdParts := []string{parser.ConvertCommand}
-		cmdParts = append(cmdParts, args...)
-
-		err = NewParserError(
-			"ImageMagick convert command failed: "+cmdErr.Error(),
+	if cmdErr := parser.run(ctx, nil, &stdout, &stderr, args...); cmdErr != nil {
+		err = parser.newCommandError(
+			cmdErr,
 			"",
-			strings.Join(cmdParts, " "),
+			args,
 			stdout.Bytes(),
 			stderr.Bytes(),
 		)
@@ -222,3 +386,77 @@ func (parser *Parser) Convert(args ...string) (stdOut *[]byte, stdErr *[]byte, e
 	return &stdOutBytes, &stdErrBytes, err
 
 }
+
+// run executes the `convert` command with the given arguments and waits for it to exit.  If ctx
+// is done first, the process is killed along with its process group so delegates started by
+// ImageMagick (like ghostscript) don't outlive it, and the context error is returned.  The
+// Parser.Limits are added before the arguments.
+func (parser *Parser) run(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, args ...string) error {
+	name, argv := parser.ToolCommand("convert", parser.convertArgs(args)...)
+	return parser.runCommand(ctx, stdin, stdout, stderr, name, argv...)
+}
+
+// runCommand executes an arbitrary command with the Parser.Env, Parser.TempDir and Parser.Dir
+// settings and waits for it to exit, killing it and its process group if ctx is done first
+func (parser *Parser) runCommand(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, name string, args ...string) error {
+	// Don't bother starting a process that would be killed right away
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+
+	cmd := parser.command(name, args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.Env = parser.processEnv(cmd.Env)
+	if parser.Dir != "" {
+		cmd.Dir = parser.Dir
+	}
+	setProcessGroup(cmd)
+	setWaitDelay(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	exited := make(chan struct{})
+	defer close(exited)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			killProcessGroup(cmd)
+		case <-exited:
+		}
+	}()
+
+	err := cmd.Wait()
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// newCommandError creates a ParserError for a failed `convert` run, marking it as a timeout or
+// cancellation if that's why the command failed
+func (parser *Parser) newCommandError(cmdErr error, file string, args []string, stdOut []byte, stdErr []byte) *ParserError {
+	msg := "ImageMagick convert command failed: " + cmdErr.Error()
+	switch cmdErr {
+	case context.DeadlineExceeded:
+		msg = "ImageMagick convert command timed out: " + cmdErr.Error()
+	case context.Canceled:
+		msg = "ImageMagick convert command was canceled: " + cmdErr.Error()
+	}
+
+	err := NewParserError(msg, file, parser.commandString(args), stdOut, stdErr)
+	err.cause = cmdErr
+	return err
+}
+
+// commandString returns the `convert` command line for the given arguments, for error reporting
+func (parser *Parser) commandString(args []string) string {
+	name, argv := parser.ToolCommand("convert", parser.convertArgs(args)...)
+	cmdParts := []string{name}
+	cmdParts = append(cmdParts, argv...)
+	return strings.Join(cmdParts, " ")
+}
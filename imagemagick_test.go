@@ -1,6 +1,7 @@
 package imagemagick_test
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -9,6 +10,7 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/kamermans/imagemagick"
 	test "github.com/kamermans/imagemagick/test_resources"
@@ -596,3 +598,136 @@ func TestHelperGetImageDetailsParallelWithErrors(t *testing.T) {
 	os.Stdout.Write(jsonBlob)
 
 }
+
+func TestGetImageDetailsContextTimeout(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperSlowCommand")
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	startTime := time.Now()
+	_, err := parser.GetImageDetailsContext(ctx, "/foo/bar/slow.tif")
+	if err == nil {
+		t.Fatalf("GetImageDetailsContext() did not fail as expected")
+	}
+
+	if elapsed := time.Since(startTime); elapsed > 5*time.Second {
+		t.Fatalf("GetImageDetailsContext() failed: command was not killed, took %v", elapsed)
+	}
+
+	if !err.Timeout() || err.Canceled() {
+		t.Fatalf("GetImageDetailsContext() failed: expected a timeout error, got %v", err.Error())
+	}
+
+	if err.Unwrap() != context.DeadlineExceeded {
+		t.Fatalf("GetImageDetailsContext() failed: expected cause %v, got %v", context.DeadlineExceeded, err.Unwrap())
+	}
+}
+
+func TestConvertContextCanceled(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperSlowCommand")
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		cancel()
+	}()
+
+	_, _, err := parser.ConvertContext(ctx, "foo.tif", "bar.png")
+	if err == nil {
+		t.Fatalf("ConvertContext() did not fail as expected")
+	}
+
+	if !err.Canceled() || err.Timeout() {
+		t.Fatalf("ConvertContext() failed: expected a cancellation error, got %v", err.Error())
+	}
+
+	// A context that is already done should not start a process at all
+	_, _, err = parser.ConvertContext(ctx, "foo.tif", "bar.png")
+	if err == nil || !err.Canceled() {
+		t.Fatalf("ConvertContext() failed: expected a cancellation error, got %v", err)
+	}
+
+	if mockExec.RunCount() != 1 {
+		t.Fatalf("ConvertContext() failed: expected 1 run, got %v", mockExec.RunCount())
+	}
+}
+
+func TestHelperSlowCommand(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	time.Sleep(30 * time.Second)
+}
+
+func TestGetImageDetailsParallelContextCanceled(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperSlowCommand")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+	parser.Workers = 2
+	parser.BatchSize = 2
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	files := make(chan string)
+	results := make(chan *imagemagick.ImageResult)
+	errs := make(chan *imagemagick.ParserError)
+
+	parser.GetImageDetailsParallelContext(ctx, files, results, errs)
+
+	// Send in files until the context is done
+	go func() {
+		defer close(files)
+		for i := 0; ; i++ {
+			select {
+			case files <- fmt.Sprintf("/foo/bar/test_file/%d", i):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	receivedErrors := []*imagemagick.ParserError{}
+
+	startTime := time.Now()
+	moreErrs := true
+	moreResults := true
+	for moreErrs || moreResults {
+		select {
+		case err, ok := <-errs:
+			if !ok {
+				moreErrs = false
+				continue
+			}
+			receivedErrors = append(receivedErrors, err)
+		case _, ok := <-results:
+			if !ok {
+				moreResults = false
+				continue
+			}
+			t.Fatalf("GetImageDetailsParallelContext() failed: received an unexpected result")
+		}
+	}
+
+	if elapsed := time.Since(startTime); elapsed > 5*time.Second {
+		t.Fatalf("GetImageDetailsParallelContext() failed: commands were not killed, took %v", elapsed)
+	}
+
+	if len(receivedErrors) == 0 {
+		t.Fatalf("GetImageDetailsParallelContext() failed: expected errors for the batches in progress")
+	}
+
+	for _, err := range receivedErrors {
+		if !err.Timeout() {
+			t.Fatalf("GetImageDetailsParallelContext() failed: expected a timeout error, got %v", err.Error())
+		}
+	}
+}
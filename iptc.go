@@ -0,0 +1,335 @@
+package imagemagick
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// IPTCRecord is a single IPTC-IIM dataset, like 2:25 (a keyword)
+type IPTCRecord struct {
+	Record  int
+	Dataset int
+	Value   string
+}
+
+// Tag returns the record and dataset numbers, like "2:25"
+func (r IPTCRecord) Tag() string {
+	return fmt.Sprintf("%d:%d", r.Record, r.Dataset)
+}
+
+// IPTC is the editorial metadata of an image from its IPTC-IIM profile.  Records has all the
+// datasets, including the ones without a field.
+type IPTC struct {
+	ObjectName             string   // 2:05
+	Category               string   // 2:15
+	SupplementalCategories []string // 2:20
+	Keywords               []string // 2:25
+	SpecialInstructions    string   // 2:40
+	DateCreated            string   // 2:55, like "20170705"
+	TimeCreated            string   // 2:60, like "150235+0200"
+	Byline                 []string // 2:80
+	BylineTitle            string   // 2:85
+	City                   string   // 2:90
+	SubLocation            string   // 2:92
+	ProvinceState          string   // 2:95
+	CountryCode            string   // 2:100
+	Country                string   // 2:101
+	Headline               string   // 2:105
+	Credit                 string   // 2:110
+	Source                 string   // 2:115
+	Copyright              string   // 2:116
+	Caption                string   // 2:120
+	CaptionWriter          string   // 2:122
+
+	Records []IPTCRecord
+}
+
+// The names of the IPTC application record (2) datasets, which ImageMagick may use in the
+// "iptc:*" properties instead of the numbers
+var iptcDatasetNames = map[string]int{
+	"objectname":                  5,
+	"category":                    15,
+	"supplementalcategories":      20,
+	"supplementalcategory":        20,
+	"keywords":                    25,
+	"keyword":                     25,
+	"specialinstructions":         40,
+	"datecreated":                 55,
+	"timecreated":                 60,
+	"byline":                      80,
+	"by-line":                     80,
+	"bylinetitle":                 85,
+	"by-linetitle":                85,
+	"city":                        90,
+	"sublocation":                 92,
+	"sub-location":                92,
+	"provincestate":               95,
+	"province-state":              95,
+	"countrycode":                 100,
+	"country-primarylocationcode": 100,
+	"country":                     101,
+	"country-primarylocationname": 101,
+	"headline":                    105,
+	"credit":                      110,
+	"source":                      115,
+	"copyright":                   116,
+	"copyrightnotice":             116,
+	"caption":                     120,
+	"caption-abstract":            120,
+	"captionwriter":               122,
+	"writer-editor":               122,
+}
+
+// add sets the field of an application record dataset
+func (iptc *IPTC) add(record IPTCRecord) {
+	iptc.Records = append(iptc.Records, record)
+	if record.Record != 2 {
+		return
+	}
+
+	value := record.Value
+	switch record.Dataset {
+	case 5:
+		iptc.ObjectName = value
+	case 15:
+		iptc.Category = value
+	case 20:
+		iptc.SupplementalCategories = append(iptc.SupplementalCategories, value)
+	case 25:
+		iptc.Keywords = append(iptc.Keywords, value)
+	case 40:
+		iptc.SpecialInstructions = value
+	case 55:
+		iptc.DateCreated = value
+	case 60:
+		iptc.TimeCreated = value
+	case 80:
+		iptc.Byline = append(iptc.Byline, value)
+	case 85:
+		iptc.BylineTitle = value
+	case 90:
+		iptc.City = value
+	case 92:
+		iptc.SubLocation = value
+	case 95:
+		iptc.ProvinceState = value
+	case 100:
+		iptc.CountryCode = value
+	case 101:
+		iptc.Country = value
+	case 105:
+		iptc.Headline = value
+	case 110:
+		iptc.Credit = value
+	case 115:
+		iptc.Source = value
+	case 116:
+		iptc.Copyright = value
+	case 120:
+		iptc.Caption = value
+	case 122:
+		iptc.CaptionWriter = value
+	}
+}
+
+// The repeatable application record datasets, which ImageMagick joins with ";"
+var iptcRepeatable = map[int]bool{
+	20: true,
+	25: true,
+	80: true,
+}
+
+// IPTC returns the IPTC metadata from the "iptc:*" properties, like "iptc:2:25" or
+// "iptc:Keywords", or nil if there are none.  ImageMagick joins repeated datasets (like keywords)
+// with ";", so only those are split, and other values like the caption are kept whole.
+// ImageMagick doesn't always report the IPTC properties, so use Parser.GetIPTC to decode the
+// profile itself.
+func (details ImageDetails) IPTC() *IPTC {
+	properties, ok := details.PropertiesMap("iptc")["iptc"]
+	if !ok || len(properties) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	iptc := &IPTC{}
+	for _, key := range keys {
+		record, dataset, ok := iptcTag(key)
+		if !ok {
+			continue
+		}
+
+		values := []string{properties[key]}
+		if record == 2 && iptcRepeatable[dataset] {
+			values = strings.Split(properties[key], ";")
+		}
+		for _, value := range values {
+			if value = strings.TrimSpace(value); value != "" {
+				iptc.add(IPTCRecord{Record: record, Dataset: dataset, Value: value})
+			}
+		}
+	}
+
+	if len(iptc.Records) == 0 {
+		return nil
+	}
+	return iptc
+}
+
+// iptcTag returns the record and dataset of an "iptc:*" property key, like "2:25" or "Keywords"
+func iptcTag(key string) (record int, dataset int, ok bool) {
+	if parts := strings.SplitN(key, ":", 2); len(parts) == 2 {
+		var recordErr, datasetErr error
+		record, recordErr = strconv.Atoi(parts[0])
+		dataset, datasetErr = strconv.Atoi(parts[1])
+		return record, dataset, recordErr == nil && datasetErr == nil
+	}
+
+	dataset, ok = iptcDatasetNames[strings.ToLower(strings.Replace(key, " ", "", -1))]
+	return 2, dataset, ok
+}
+
+// ParseIPTC decodes an IPTC-IIM profile, as written by `convert file iptc:-`.  A Photoshop "8BIM"
+// resource block is also accepted, in which case the IPTC resource is decoded.  Text is decoded as
+// UTF-8 if the profile declares it (or it is valid UTF-8), and as ISO-8859-1 otherwise.
+func ParseIPTC(data []byte) (iptc *IPTC, err error) {
+	if len(data) >= 4 && string(data[:4]) == "8BIM" {
+		if data, err = photoshopResource(data, 0x0404); err != nil {
+			return nil, err
+		}
+	}
+
+	// Skip any padding before the first tag marker
+	start := 0
+	for start < len(data) && data[start] != 0x1C {
+		start++
+	}
+	if start == len(data) {
+		return nil, fmt.Errorf("Invalid IPTC profile: no datasets found")
+	}
+	data = data[start:]
+
+	iptc = &IPTC{}
+	utf8Declared := false
+	for len(data) > 0 && data[0] == 0x1C {
+		if len(data) < 5 {
+			return nil, fmt.Errorf("Invalid IPTC profile: truncated dataset header")
+		}
+
+		record, dataset := int(data[1]), int(data[2])
+		length := int(binary.BigEndian.Uint16(data[3:5]))
+		data = data[5:]
+
+		// Extended datasets store the number of length bytes in the lower 15 bits
+		if length&0x8000 != 0 {
+			size := length & 0x7FFF
+			if size > 4 || len(data) < size {
+				return nil, fmt.Errorf("Invalid IPTC profile: bad extended length in %d:%d", record, dataset)
+			}
+			length = 0
+			for _, b := range data[:size] {
+				length = length<<8 | int(b)
+			}
+			data = data[size:]
+		}
+
+		if length < 0 || length > len(data) {
+			return nil, fmt.Errorf("Invalid IPTC profile: %d:%d is truncated", record, dataset)
+		}
+		value := data[:length]
+		data = data[length:]
+
+		// 1:90 is the coded character set, where "ESC % G" is UTF-8
+		if record == 1 && dataset == 90 {
+			utf8Declared = string(value) == "\x1b%G"
+		}
+
+		iptc.add(IPTCRecord{Record: record, Dataset: dataset, Value: decodeIPTCString(value, utf8Declared)})
+	}
+
+	return iptc, nil
+}
+
+// decodeIPTCString decodes a dataset value as UTF-8 or ISO-8859-1, trimming NUL padding
+func decodeIPTCString(value []byte, utf8Declared bool) string {
+	value = []byte(strings.TrimRight(string(value), "\x00"))
+	if utf8Declared || utf8.Valid(value) {
+		return string(value)
+	}
+
+	runes := make([]rune, len(value))
+	for i, b := range value {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// photoshopResource returns the data of a resource from a Photoshop "8BIM" resource block
+func photoshopResource(data []byte, id uint16) ([]byte, error) {
+	for len(data) > 0 {
+		if len(data) < 7 || string(data[:4]) != "8BIM" {
+			return nil, fmt.Errorf("Invalid 8BIM profile: bad resource header")
+		}
+		resourceID := binary.BigEndian.Uint16(data[4:6])
+
+		// The name is a Pascal string, padded to an even size
+		nameSize := int(data[6]) + 1
+		nameSize += nameSize % 2
+		if len(data) < 6+nameSize+4 {
+			return nil, fmt.Errorf("Invalid 8BIM profile: truncated resource header")
+		}
+		data = data[6+nameSize:]
+
+		size := int(binary.BigEndian.Uint32(data[:4]))
+		data = data[4:]
+		if size < 0 || size > len(data) {
+			return nil, fmt.Errorf("Invalid 8BIM profile: truncated resource")
+		}
+
+		if resourceID == id {
+			return data[:size], nil
+		}
+
+		// The data is also padded to an even size
+		size += size % 2
+		if size > len(data) {
+			break
+		}
+		data = data[size:]
+	}
+
+	return nil, fmt.Errorf("%w: 8BIM resource 0x%04X", ErrProfileNotFound, id)
+}
+
+// GetIPTC extracts the IPTC profile from the file with `convert file iptc:-` and decodes it.  The
+// error matches ErrProfileNotFound with errors.Is() if the file has no IPTC profile.  The file
+// name is taken literally, as with GetImageDetailsWithOptions.
+func (parser *Parser) GetIPTC(file string) (iptc *IPTC, err *ParserError) {
+	return parser.GetIPTCContext(context.Background(), file)
+}
+
+// GetIPTCContext is like GetIPTC, but the `convert` process is killed if ctx is canceled or its
+// deadline expires before it exits
+func (parser *Parser) GetIPTCContext(ctx context.Context, file string) (iptc *IPTC, err *ParserError) {
+	profile, err := parser.extractProfile(ctx, file, "iptc")
+	if err != nil {
+		return
+	}
+
+	iptc, parseErr := ParseIPTC(profile)
+	if parseErr != nil {
+		err = NewParserError(parseErr.Error(), file, "", profile, []byte{})
+		err.cause = parseErr
+		return nil, err
+	}
+	return iptc, nil
+}
@@ -0,0 +1,149 @@
+package imagemagick_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/kamermans/imagemagick"
+	test "github.com/kamermans/imagemagick/test_resources"
+)
+
+// iptcDataset encodes an IPTC-IIM dataset
+func iptcDataset(record byte, dataset byte, value string) []byte {
+	data := []byte{0x1C, record, dataset, 0, 0}
+	binary.BigEndian.PutUint16(data[3:], uint16(len(value)))
+	return append(data, value...)
+}
+
+// testIPTCProfile is a UTF-8 IPTC profile with two keywords
+func testIPTCProfile() []byte {
+	var profile bytes.Buffer
+	profile.Write(iptcDataset(1, 90, "\x1b%G"))
+	profile.Write(iptcDataset(2, 0, "\x00\x04"))
+	profile.Write(iptcDataset(2, 5, "Harbor"))
+	profile.Write(iptcDataset(2, 25, "boats"))
+	profile.Write(iptcDataset(2, 25, "sunset"))
+	profile.Write(iptcDataset(2, 80, "Jane Doe"))
+	profile.Write(iptcDataset(2, 90, "Málaga"))
+	profile.Write(iptcDataset(2, 101, "Spain"))
+	profile.Write(iptcDataset(2, 110, "Example Press"))
+	profile.Write(iptcDataset(2, 116, "© 2017 Jane Doe"))
+	profile.Write(iptcDataset(2, 120, "Boats in the harbor at sunset"))
+	// Padding
+	profile.Write([]byte{0, 0, 0})
+	return profile.Bytes()
+}
+
+func checkTestIPTC(t *testing.T, iptc *imagemagick.IPTC) {
+	if iptc.ObjectName != "Harbor" || iptc.City != "Málaga" || iptc.Country != "Spain" ||
+		iptc.Credit != "Example Press" || iptc.Copyright != "© 2017 Jane Doe" ||
+		iptc.Caption != "Boats in the harbor at sunset" {
+		t.Fatalf("unexpected IPTC fields: %+v", iptc)
+	}
+	if !reflect.DeepEqual(iptc.Keywords, []string{"boats", "sunset"}) {
+		t.Fatalf("unexpected IPTC keywords: %v", iptc.Keywords)
+	}
+	if !reflect.DeepEqual(iptc.Byline, []string{"Jane Doe"}) {
+		t.Fatalf("unexpected IPTC byline: %v", iptc.Byline)
+	}
+}
+
+func TestParseIPTC(t *testing.T) {
+	iptc, err := imagemagick.ParseIPTC(testIPTCProfile())
+	if err != nil {
+		t.Fatalf("ParseIPTC() failed: %v", err)
+	}
+	checkTestIPTC(t, iptc)
+
+	if len(iptc.Records) != 11 || iptc.Records[4].Tag() != "2:25" {
+		t.Fatalf("ParseIPTC() failed: unexpected records %v", iptc.Records)
+	}
+
+	// Without a character set, invalid UTF-8 is decoded as ISO-8859-1
+	iptc, err = imagemagick.ParseIPTC(iptcDataset(2, 90, "M\xe1laga"))
+	if err != nil || iptc.City != "Málaga" {
+		t.Fatalf("ParseIPTC() failed to decode ISO-8859-1: %q, %v", iptc.City, err)
+	}
+
+	// Extended length
+	extended := append([]byte{0x1C, 2, 120, 0x80, 0x02, 0x00, 0x05}, "hello"...)
+	iptc, err = imagemagick.ParseIPTC(extended)
+	if err != nil || iptc.Caption != "hello" {
+		t.Fatalf("ParseIPTC() failed to decode an extended dataset: %q, %v", iptc.Caption, err)
+	}
+
+	// Photoshop resource block with an unrelated resource first
+	resources := []byte("8BIM\x04\x25\x00\x00\x00\x00\x00\x03abc\x00")
+	resources = append(resources, "8BIM\x04\x04\x00\x00"...)
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(len(testIPTCProfile())))
+	resources = append(append(resources, size...), testIPTCProfile()...)
+	iptc, err = imagemagick.ParseIPTC(resources)
+	if err != nil {
+		t.Fatalf("ParseIPTC() failed to decode an 8BIM block: %v", err)
+	}
+	checkTestIPTC(t, iptc)
+
+	for name, data := range map[string][]byte{
+		"empty":     {},
+		"truncated": iptcDataset(2, 120, "hello")[:8],
+		"header":    {0x1C, 2, 120},
+		"no 8BIM":   []byte("8BIM\x04\x25\x00\x00\x00\x00\x00\x00"),
+	} {
+		if _, err := imagemagick.ParseIPTC(data); err == nil {
+			t.Fatalf("ParseIPTC() should fail for %v", name)
+		}
+	}
+}
+
+func TestIPTCFromProperties(t *testing.T) {
+	details := imagemagick.ImageDetails{Properties: map[string]string{
+		"iptc:2:25":    "boats;sunset",
+		"iptc:Credit":  "Example Press",
+		"iptc:City":    "Málaga",
+		"iptc:2:80":    "Jane Doe; John Doe",
+		"iptc:2:120":   "Sunset; Malaga beach",
+		"iptc:Unknown": "ignored",
+		"exif:Make":    "Canon",
+	}}
+
+	iptc := details.IPTC()
+	if iptc == nil {
+		t.Fatalf("IPTC() failed: expected IPTC data")
+	}
+	if !reflect.DeepEqual(iptc.Keywords, []string{"boats", "sunset"}) || iptc.Credit != "Example Press" || iptc.City != "Málaga" {
+		t.Fatalf("IPTC() failed: %+v", iptc)
+	}
+	if !reflect.DeepEqual(iptc.Byline, []string{"Jane Doe", "John Doe"}) || iptc.Caption != "Sunset; Malaga beach" {
+		t.Fatalf("IPTC() failed: only repeatable datasets should be split: %+v", iptc)
+	}
+
+	if iptc := (imagemagick.ImageDetails{Properties: map[string]string{"exif:Make": "Canon"}}).IPTC(); iptc != nil {
+		t.Fatalf("IPTC() failed: expected nil, got %+v", iptc)
+	}
+}
+
+func TestGetIPTC(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperExtractProfile")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+
+	iptc, err := parser.GetIPTC("/foo/photo.jpg")
+	if err != nil {
+		t.Fatalf("GetIPTC() failed: %v", err.Error())
+	}
+	checkTestIPTC(t, iptc)
+
+	if run := mockExec.Runs()[0]; !reflect.DeepEqual(run.Args()[len(run.Args())-2:], []string{"/foo/photo.jpg[0]", "IPTC:-"}) {
+		t.Fatalf("GetIPTC() failed: unexpected args %v", run.Args())
+	}
+
+	_, err = parser.GetIPTC("/foo/noprofile.jpg")
+	if err == nil || !errors.Is(err, imagemagick.ErrProfileNotFound) {
+		t.Fatalf("GetIPTC() failed: expected ErrProfileNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package imagemagick
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// ResourceLimits caps the resources a `convert` run can use, which protects the system from
+// images that are crafted (or just big enough) to use gigabytes of memory or run for minutes.
+// Zero values are not passed to `convert`, so the ImageMagick defaults and policy.xml apply.
+// When a limit is reached, ImageMagick fails with a CategoryResourceLimit diagnostic.
+type ResourceLimits struct {
+	// Memory is the maximum pixel cache memory in bytes, beyond which the pixel cache is mapped
+	// to disk
+	Memory int64
+	// Map is the maximum memory mapped pixel cache in bytes, beyond which it goes to disk
+	Map int64
+	// Disk is the maximum disk space for the pixel cache in bytes, beyond which the run fails
+	Disk int64
+	// Area is the maximum number of pixels of a single image in the pixel cache
+	Area int64
+	// Width and Height are the maximum image dimensions in pixels
+	Width  int64
+	Height int64
+	// Time is the maximum run time, which is rounded up to whole seconds
+	Time time.Duration
+	// Thread is the maximum number of threads ImageMagick uses for a run
+	Thread int
+}
+
+// Args returns the `-limit` options for the limits that are set, like
+// ["-limit", "memory", "268435456", "-limit", "time", "30"]
+func (limits ResourceLimits) Args() (args []string) {
+	for _, limit := range limits.values() {
+		args = append(args, "-limit", limit[0], limit[1])
+	}
+	return
+}
+
+// values returns the ImageMagick resource names and values of the limits that are set
+func (limits ResourceLimits) values() (values [][2]string) {
+	add := func(resource string, value int64) {
+		if value > 0 {
+			values = append(values, [2]string{resource, strconv.FormatInt(value, 10)})
+		}
+	}
+
+	add("memory", limits.Memory)
+	add("map", limits.Map)
+	add("disk", limits.Disk)
+	add("area", limits.Area)
+	add("width", limits.Width)
+	add("height", limits.Height)
+	add("time", int64(math.Ceil(limits.Time.Seconds())))
+	add("thread", int64(limits.Thread))
+
+	return
+}
+
+// convertArgs returns the arguments for a `convert` run, with the resource limits first so they
+// apply to reading the input files
+func (parser *Parser) convertArgs(args []string) []string {
+	limits := parser.Limits.Args()
+	if len(limits) == 0 {
+		return args
+	}
+	return append(limits, args...)
+}
+
+// processEnv returns the environment for a child process, which is the given environment (or the
+// environment of this process if it is nil) with Parser.Env, Parser.TempDir and
+// Parser.ConfigurePath added.  It returns env unchanged if there is nothing to add.
+func (parser *Parser) processEnv(env []string) []string {
+	if len(parser.Env) == 0 && parser.TempDir == "" && parser.ConfigurePath == "" {
+		return env
+	}
+
+	if env == nil {
+		env = os.Environ()
+	}
+
+	// Copy, so the variables are added to a new slice
+	processEnv := make([]string, 0, len(env)+len(parser.Env)+3)
+	processEnv = append(processEnv, env...)
+
+	// ImageMagick uses MAGICK_TEMPORARY_PATH, its delegates (like ghostscript) use TMPDIR
+	if parser.TempDir != "" {
+		processEnv = append(processEnv, "MAGICK_TEMPORARY_PATH="+parser.TempDir, "TMPDIR="+parser.TempDir)
+	}
+
+	if parser.ConfigurePath != "" {
+		processEnv = append(processEnv, "MAGICK_CONFIGURE_PATH="+parser.ConfigurePath)
+	}
+
+	// Added last, so they win over the inherited variables
+	return append(processEnv, parser.Env...)
+}
+
+// localPath returns the path of a file as the ImageMagick processes see it, which is relative to
+// Parser.Dir when it is set
+func (parser *Parser) localPath(file string) string {
+	if parser.Dir == "" || filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(parser.Dir, file)
+}
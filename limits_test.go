@@ -0,0 +1,141 @@
+package imagemagick_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kamermans/imagemagick"
+	test "github.com/kamermans/imagemagick/test_resources"
+)
+
+// TestHelperProcessSettings mocks `convert` and prints its arguments, working directory and
+// the environment variables set by the Parser.  It fails when JSON output is requested.
+func TestHelperProcessSettings(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	args := helperArgs()
+	if args[len(args)-1] == "json:-" {
+		os.Exit(1)
+	}
+
+	dir, _ := os.Getwd()
+	fmt.Println(strings.Join(args, " "))
+	fmt.Println(filepath.Base(dir))
+	fmt.Println(os.Getenv("MAGICK_TEMPORARY_PATH"))
+	fmt.Println(os.Getenv("MAGICK_THREAD_LIMIT"))
+}
+
+func TestResourceLimitsArgs(t *testing.T) {
+	limits := imagemagick.ResourceLimits{
+		Memory: 256 << 20,
+		Area:   100000000,
+		Time:   1500 * time.Millisecond,
+		Thread: 2,
+	}
+
+	expected := "-limit memory 268435456 -limit area 100000000 -limit time 2 -limit thread 2"
+	if actual := strings.Join(limits.Args(), " "); actual != expected {
+		t.Fatalf("ResourceLimits.Args() failed: expected %q, got %q", expected, actual)
+	}
+
+	if len(imagemagick.ResourceLimits{}.Args()) != 0 {
+		t.Fatalf("ResourceLimits.Args() failed: expected no args for zero limits")
+	}
+}
+
+func TestParserProcessSettings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imagemagick")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	mockExec := test.NewMockExec("TestHelperProcessSettings")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+	parser.Limits = imagemagick.ResourceLimits{Memory: 1024, Width: 8000}
+	parser.Env = []string{"MAGICK_THREAD_LIMIT=1"}
+	parser.Dir = dir
+	parser.TempDir = "/var/tmp/magick"
+
+	stdOut, _, parserErr := parser.Convert("in.png", "out.jpg")
+	if parserErr != nil {
+		t.Fatalf("Convert() failed: %v", parserErr.Error())
+	}
+
+	expected := strings.Join([]string{
+		"-limit memory 1024 -limit width 8000 in.png out.jpg",
+		filepath.Base(dir),
+		"/var/tmp/magick",
+		"1",
+		"",
+	}, "\n")
+	if string(*stdOut) != expected {
+		t.Fatalf("Convert() failed: expected %q, got %q", expected, *stdOut)
+	}
+
+	// The limits are part of the command in errors
+	parser.SafeFilenames = true
+	_, parserErr = parser.GetImageDetails("image.png")
+	if parserErr == nil || !strings.Contains(parserErr.Cmd(), "-limit memory 1024 -limit width 8000 PNG:image.png json:-") {
+		t.Fatalf("GetImageDetails() failed: expected the limits in the command, got %v", parserErr)
+	}
+}
+
+func TestParserDirInputs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imagemagick")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "image.png"), quickTestImages(t)["image.png"], 0644); err != nil {
+		t.Fatalf("Unable to write image.png: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "fake.png"), []byte("not an image"), 0644); err != nil {
+		t.Fatalf("Unable to write fake.png: %v", err)
+	}
+
+	mockExec := test.NewMockExec("TestHelperSafeFilenames")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+	parser.Dir = dir
+	parser.SniffInputs = true
+	parser.SafeFilenames = true
+
+	// The files are sniffed in Dir, where convert reads them
+	_, parserErr := parser.GetImageDetails("fake.png")
+	if parserErr == nil || !errors.Is(parserErr, imagemagick.ErrUnknownFormat) {
+		t.Fatalf("GetImageDetails() failed: expected ErrUnknownFormat, got %v", parserErr)
+	}
+
+	_, parserErr = parser.GetImageDetails("image.png")
+	if parserErr == nil || string(parserErr.StdErr()) != "PNG:image.png\njson:-" {
+		t.Fatalf("GetImageDetails() failed: expected the sniffed coder, got %v", parserErr)
+	}
+
+	// The quick details are decoded from the file in Dir, without running convert
+	parser.QuickDetails = true
+	results, parserErr := parser.GetImageDetails("image.png")
+	if parserErr != nil {
+		t.Fatalf("GetImageDetails() failed: %v", parserErr.Error())
+	}
+	if len(results) != 1 || results[0].File != "image.png" || results[0].Image.Geometry.Width != 30 {
+		t.Fatalf("GetImageDetails() failed: unexpected results %v", results)
+	}
+
+	if mockExec.RunCount() != 1 {
+		t.Fatalf("GetImageDetails() failed: expected 1 run, got %v", mockExec.RunCount())
+	}
+}
@@ -0,0 +1,220 @@
+package imagemagick
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MagickInfo describes an ImageMagick installation, as reported by `convert -version`
+type MagickInfo struct {
+	// Binary is the path to the ImageMagick command, either `magick` (ImageMagick 7) or `convert`
+	Binary string
+	// Version is the full version string, like "7.1.1-15"
+	Version string
+	// Major, Minor, Patch and Release are the components of Version
+	Major   int
+	Minor   int
+	Patch   int
+	Release int
+	// QuantumDepth is the number of bits per pixel component (Q8, Q16, Q32)
+	QuantumDepth int
+	// HDRI is true when ImageMagick was built with high dynamic-range imaging support
+	HDRI bool
+	// Features lists the optional features, like "Cipher", "DPC", "OpenMP(4.5)"
+	Features []string
+	// Delegates lists the built-in delegate libraries, like "jpeg", "png", "webp"
+	Delegates []string
+}
+
+var magickVersionPattern = regexp.MustCompile(`ImageMagick (\d+)\.(\d+)\.(\d+)(?:-(\d+))?\s+Q(\d+)(-HDRI)?`)
+
+// ParseMagickInfo parses the output of `convert -version` or `magick -version` into a MagickInfo.
+// The Binary field is left empty.
+func ParseMagickInfo(versionOutput []byte) (info *MagickInfo, err error) {
+	info = &MagickInfo{}
+	foundVersion := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(versionOutput))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+
+		switch {
+		case key == "Version":
+			match := magickVersionPattern.FindStringSubmatch(value)
+			if match == nil {
+				continue
+			}
+			foundVersion = true
+
+			info.Major, _ = strconv.Atoi(match[1])
+			info.Minor, _ = strconv.Atoi(match[2])
+			info.Patch, _ = strconv.Atoi(match[3])
+			info.Release, _ = strconv.Atoi(match[4])
+			info.QuantumDepth, _ = strconv.Atoi(match[5])
+			info.HDRI = match[6] != ""
+
+			info.Version = fmt.Sprintf("%d.%d.%d", info.Major, info.Minor, info.Patch)
+			if match[4] != "" {
+				info.Version += "-" + match[4]
+			}
+		case key == "Features":
+			info.Features = strings.Fields(value)
+		case strings.HasPrefix(key, "Delegates"):
+			// "Delegates (built-in)" on most builds, just "Delegates" on some older ones
+			info.Delegates = strings.Fields(value)
+		}
+	}
+
+	if !foundVersion {
+		return nil, fmt.Errorf("Unable to find the ImageMagick version in the output: %q", versionOutput)
+	}
+
+	if info.HasFeature("HDRI") {
+		info.HDRI = true
+	}
+
+	return info, nil
+}
+
+// IsMagick returns true if the binary is the ImageMagick 7 `magick` command
+func (info *MagickInfo) IsMagick() bool {
+	return isMagickBinary(info.Binary)
+}
+
+// HasFeature returns true if ImageMagick was built with the given feature, like "OpenMP" or "HDRI".
+// Version suffixes like "OpenMP(4.5)" are ignored.
+func (info *MagickInfo) HasFeature(name string) bool {
+	return containsFold(info.Features, name)
+}
+
+// HasDelegate returns true if ImageMagick was built with the given delegate library, like "webp"
+func (info *MagickInfo) HasDelegate(name string) bool {
+	return containsFold(info.Delegates, name)
+}
+
+// AtLeast returns true if the ImageMagick version is major.minor.patch or newer
+func (info *MagickInfo) AtLeast(major, minor, patch int) bool {
+	if info.Major != major {
+		return info.Major > major
+	}
+	if info.Minor != minor {
+		return info.Minor > minor
+	}
+	return info.Patch >= patch
+}
+
+// String representation
+func (info MagickInfo) String() string {
+	hdri := ""
+	if info.HDRI {
+		hdri = "-HDRI"
+	}
+	return fmt.Sprintf("ImageMagick %v Q%d%v (%v)", info.Version, info.QuantumDepth, hdri, info.Binary)
+}
+
+func containsFold(list []string, name string) bool {
+	for _, item := range list {
+		if i := strings.Index(item, "("); i > 0 {
+			item = item[:i]
+		}
+		if strings.EqualFold(item, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func isMagickBinary(binary string) bool {
+	base := filepath.Base(binary)
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	return strings.EqualFold(base, "magick")
+}
+
+// Detect finds the ImageMagick command on the PATH, preferring the ImageMagick 7 `magick` command
+// over `convert`, which is missing or prints deprecation warnings on ImageMagick 7 installs.  The
+// command is probed with `-version` and on success Parser.ConvertCommand and Parser.Magick are set.
+func (parser *Parser) Detect() (info *MagickInfo, err *ParserError) {
+	return parser.DetectContext(context.Background())
+}
+
+// DetectContext is like Detect, but the probing commands are killed if ctx is done before they exit
+func (parser *Parser) DetectContext(ctx context.Context) (info *MagickInfo, err *ParserError) {
+	for _, name := range []string{"magick", "convert"} {
+		binary, lookErr := parser.lookPath(name)
+		if lookErr != nil {
+			continue
+		}
+
+		info, err = parser.probe(ctx, binary)
+		if err != nil {
+			// On Windows, `convert` may be the filesystem conversion tool, so keep looking
+			if err.Timeout() || err.Canceled() {
+				return
+			}
+			continue
+		}
+
+		parser.ConvertCommand = binary
+		parser.Magick = info
+		return
+	}
+
+	if err == nil {
+		err = NewParserError("Unable to find the ImageMagick magick or convert command in the PATH", "", "", []byte{}, []byte{})
+	}
+	return
+}
+
+// probe runs `<binary> -version` and parses the output
+func (parser *Parser) probe(ctx context.Context, binary string) (info *MagickInfo, err *ParserError) {
+	var stdout, stderr bytes.Buffer
+	cmdErr := parser.runCommand(ctx, nil, &stdout, &stderr, binary, "-version")
+	if cmdErr != nil {
+		err = parser.newCommandError(cmdErr, "", []string{"-version"}, stdout.Bytes(), stderr.Bytes())
+		err.cmd = binary + " -version"
+		return
+	}
+
+	info, parseErr := ParseMagickInfo(stdout.Bytes())
+	if parseErr != nil {
+		err = NewParserError(parseErr.Error(), "", binary+" -version", stdout.Bytes(), stderr.Bytes())
+		return
+	}
+
+	info.Binary = binary
+	return
+}
+
+// ToolCommand returns the command name and arguments needed to run an ImageMagick tool, like
+// "convert", "identify" or "mogrify", with the given arguments.  ImageMagick 7 provides every tool
+// through the `magick` command, so when ConvertCommand is `magick` the "convert" arguments are
+// passed to it directly and other tools are run as subcommands (`magick identify ...`).  Otherwise
+// the other tools are expected to be in the same directory as ConvertCommand.
+func (parser *Parser) ToolCommand(tool string, args ...string) (name string, argv []string) {
+	if tool == "convert" {
+		return parser.ConvertCommand, args
+	}
+
+	if isMagickBinary(parser.ConvertCommand) {
+		argv = make([]string, 0, len(args)+1)
+		argv = append(argv, tool)
+		argv = append(argv, args...)
+		return parser.ConvertCommand, argv
+	}
+
+	dir, file := filepath.Split(parser.ConvertCommand)
+	return dir + tool + filepath.Ext(file), args
+}
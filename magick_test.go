@@ -0,0 +1,178 @@
+package imagemagick_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/kamermans/imagemagick"
+	test "github.com/kamermans/imagemagick/test_resources"
+)
+
+const magick7VersionOutput = `Version: ImageMagick 7.1.1-15 Q16-HDRI x86_64 21298 https://imagemagick.org
+Copyright: (C) 1999 ImageMagick Studio LLC
+License: https://imagemagick.org/script/license.php
+Features: Cipher DPC HDRI Modules OpenMP(4.5)
+Delegates (built-in): bzlib djvu fontconfig freetype heic jbig jng jp2 jpeg lcms lqr ltdl lzma openexr png raw tiff webp x xml zlib
+Compiler: gcc (12.2)
+`
+
+const magick6VersionOutput = `Version: ImageMagick 6.9.11-60 Q16 x86_64 2021-01-25 https://imagemagick.org
+Copyright: (C) 1999-2021 ImageMagick Studio LLC
+License: https://imagemagick.org/script/license.php
+Features: Cipher DPC Modules OpenMP(4.5)
+Delegates (built-in): bzlib djvu fftw fontconfig freetype heic jbig jng jp2 jpeg lcms lqr ltdl lzma openexr pangocairo png tiff webp wmf x xml zlib
+`
+
+func TestParseMagickInfo(t *testing.T) {
+	info, err := imagemagick.ParseMagickInfo([]byte(magick7VersionOutput))
+	if err != nil {
+		t.Fatalf("ParseMagickInfo() failed: %v", err.Error())
+	}
+
+	if info.Version != "7.1.1-15" || info.Major != 7 || info.Minor != 1 || info.Patch != 1 || info.Release != 15 {
+		t.Fatalf("ParseMagickInfo() failed: wrong version: %+v", info)
+	}
+
+	if info.QuantumDepth != 16 || !info.HDRI {
+		t.Fatalf("ParseMagickInfo() failed: wrong quantum depth / HDRI: %+v", info)
+	}
+
+	if !info.HasFeature("OpenMP") || !info.HasFeature("cipher") || info.HasFeature("X11") {
+		t.Fatalf("ParseMagickInfo() failed: wrong features: %v", info.Features)
+	}
+
+	if !info.HasDelegate("webp") || !info.HasDelegate("heic") || info.HasDelegate("wmf") {
+		t.Fatalf("ParseMagickInfo() failed: wrong delegates: %v", info.Delegates)
+	}
+
+	if !info.AtLeast(7, 0, 0) || !info.AtLeast(7, 1, 1) || info.AtLeast(7, 1, 2) {
+		t.Fatalf("AtLeast() failed for %v", info.Version)
+	}
+
+	info, err = imagemagick.ParseMagickInfo([]byte(magick6VersionOutput))
+	if err != nil {
+		t.Fatalf("ParseMagickInfo() failed: %v", err.Error())
+	}
+
+	if info.Version != "6.9.11-60" || info.Major != 6 || info.HDRI {
+		t.Fatalf("ParseMagickInfo() failed: wrong version: %+v", info)
+	}
+
+	_, err = imagemagick.ParseMagickInfo([]byte("Invalid Parameter - -version"))
+	if err == nil {
+		t.Fatalf("ParseMagickInfo() did not fail as expected")
+	}
+}
+
+func TestDetectPrefersMagick(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperDetect")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+	parser.SetLookPath(func(file string) (string, error) {
+		return "/usr/local/bin/" + file, nil
+	})
+
+	info, err := parser.Detect()
+	if err != nil {
+		t.Fatalf("Detect() failed: %v", err.Error())
+	}
+
+	if mockExec.RunCount() != 1 {
+		t.Fatalf("Detect() failed: expected 1 run, got %v", mockExec.RunCount())
+	}
+
+	lastRun := mockExec.LastRun()
+	if lastRun.Command() != "/usr/local/bin/magick" || len(lastRun.Args()) != 1 || lastRun.Args()[0] != "-version" {
+		t.Fatalf("Detect() failed: unexpected command %v %v", lastRun.Command(), lastRun.Args())
+	}
+
+	if parser.ConvertCommand != "/usr/local/bin/magick" || parser.Magick != info || !info.IsMagick() {
+		t.Fatalf("Detect() failed: parser was not updated: %v", parser.ConvertCommand)
+	}
+
+	if info.Major != 7 {
+		t.Fatalf("Detect() failed: wrong version %v", info.Version)
+	}
+}
+
+func TestDetectFallsBackToConvert(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperDetect")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+	parser.SetLookPath(func(file string) (string, error) {
+		if file == "magick" {
+			return "", errors.New("executable file not found in $PATH")
+		}
+		return "/usr/bin/" + file, nil
+	})
+
+	info, err := parser.Detect()
+	if err != nil {
+		t.Fatalf("Detect() failed: %v", err.Error())
+	}
+
+	if parser.ConvertCommand != "/usr/bin/convert" || info.IsMagick() {
+		t.Fatalf("Detect() failed: expected convert, got %v", parser.ConvertCommand)
+	}
+}
+
+func TestDetectNotFound(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperDetect")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+	parser.SetLookPath(func(file string) (string, error) {
+		return "", errors.New("executable file not found in $PATH")
+	})
+
+	_, err := parser.Detect()
+	if err == nil {
+		t.Fatalf("Detect() did not fail as expected")
+	}
+
+	if parser.ConvertCommand != "convert" || parser.Magick != nil {
+		t.Fatalf("Detect() failed: parser should not be modified")
+	}
+}
+
+func TestHelperDetect(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	os.Stdout.Write([]byte(magick7VersionOutput))
+}
+
+func TestToolCommand(t *testing.T) {
+	parser := imagemagick.NewParser()
+
+	tests := []struct {
+		convert      string
+		tool         string
+		expectedName string
+		expectedArgs []string
+	}{
+		{"convert", "convert", "convert", []string{"a.jpg", "json:-"}},
+		{"convert", "identify", "identify", []string{"a.jpg", "json:-"}},
+		{"/usr/bin/magick", "convert", "/usr/bin/magick", []string{"a.jpg", "json:-"}},
+		{"/usr/bin/magick", "identify", "/usr/bin/magick", []string{"identify", "a.jpg", "json:-"}},
+	}
+
+	for _, tt := range tests {
+		parser.ConvertCommand = tt.convert
+		name, args := parser.ToolCommand(tt.tool, "a.jpg", "json:-")
+		if name != tt.expectedName || len(args) != len(tt.expectedArgs) {
+			t.Fatalf("ToolCommand() failed: expected %v %v, got %v %v", tt.expectedName, tt.expectedArgs, name, args)
+		}
+
+		for i := range args {
+			if args[i] != tt.expectedArgs[i] {
+				t.Fatalf("ToolCommand() failed: expected %v %v, got %v %v", tt.expectedName, tt.expectedArgs, name, args)
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package imagemagick
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Orientation is the EXIF orientation of an image, which tells how the stored pixels need to be
+// transformed to display the image upright.  The names are the ones ImageMagick uses in
+// ImageDetails.Orientation, which describe where the first row and column of the stored image are
+// displayed: RightTop means the first row is on the right side and the first column is at the top.
+type Orientation int
+
+// Orientations, with the same values as the EXIF Orientation tag
+const (
+	OrientationUndefined Orientation = iota
+	OrientationTopLeft
+	OrientationTopRight
+	OrientationBottomRight
+	OrientationBottomLeft
+	OrientationLeftTop
+	OrientationRightTop
+	OrientationRightBottom
+	OrientationLeftBottom
+)
+
+var orientationNames = []string{
+	"Undefined",
+	"TopLeft",
+	"TopRight",
+	"BottomRight",
+	"BottomLeft",
+	"LeftTop",
+	"RightTop",
+	"RightBottom",
+	"LeftBottom",
+}
+
+// ParseOrientation parses an ImageMagick orientation name like "RightTop" (case-insensitive) or an
+// EXIF orientation value from 1 to 8.  Anything else is OrientationUndefined.
+func ParseOrientation(s string) Orientation {
+	s = strings.TrimSpace(s)
+
+	if value, err := strconv.Atoi(s); err == nil {
+		if value >= int(OrientationTopLeft) && value <= int(OrientationLeftBottom) {
+			return Orientation(value)
+		}
+		return OrientationUndefined
+	}
+
+	for i, name := range orientationNames {
+		if strings.EqualFold(name, s) {
+			return Orientation(i)
+		}
+	}
+	return OrientationUndefined
+}
+
+// String representation
+func (o Orientation) String() string {
+	if o < OrientationUndefined || o > OrientationLeftBottom {
+		return orientationNames[OrientationUndefined]
+	}
+	return orientationNames[o]
+}
+
+// IsRotated90 returns true if the image is displayed rotated by 90 or 270 degrees, so its displayed
+// width and height are swapped
+func (o Orientation) IsRotated90() bool {
+	return o >= OrientationLeftTop && o <= OrientationLeftBottom
+}
+
+// IsMirrored returns true if the image is displayed mirrored
+func (o Orientation) IsMirrored() bool {
+	switch o {
+	case OrientationTopRight, OrientationBottomLeft, OrientationLeftTop, OrientationRightBottom:
+		return true
+	}
+	return false
+}
+
+// OrientationTransform is the transform that displays an image upright: mirror it horizontally if
+// Flop is set, then rotate it clockwise by Rotate degrees
+type OrientationTransform struct {
+	Flop   bool
+	Rotate int
+}
+
+// IsIdentity returns true if the transform doesn't change the image
+func (t OrientationTransform) IsIdentity() bool {
+	return !t.Flop && t.Rotate == 0
+}
+
+// Args returns the `convert` operators for the transform, like ["-flop", "-rotate", "90"], which
+// can be added with ConvertBuilder.Operator()
+func (t OrientationTransform) Args() (args []string) {
+	if t.Flop {
+		args = append(args, "-flop")
+	}
+	if t.Rotate != 0 {
+		args = append(args, "-rotate", strconv.Itoa(t.Rotate))
+	}
+	return
+}
+
+// Transform returns the transform that displays the stored pixels upright, which is what
+// `convert -auto-orient` does
+func (o Orientation) Transform() OrientationTransform {
+	switch o {
+	case OrientationTopRight:
+		return OrientationTransform{Flop: true}
+	case OrientationBottomRight:
+		return OrientationTransform{Rotate: 180}
+	case OrientationBottomLeft:
+		return OrientationTransform{Flop: true, Rotate: 180}
+	case OrientationLeftTop:
+		return OrientationTransform{Flop: true, Rotate: 270}
+	case OrientationRightTop:
+		return OrientationTransform{Rotate: 90}
+	case OrientationRightBottom:
+		return OrientationTransform{Flop: true, Rotate: 90}
+	case OrientationLeftBottom:
+		return OrientationTransform{Rotate: 270}
+	}
+	return OrientationTransform{}
+}
+
+// ImageOrientation returns the orientation of the image from ImageDetails.Orientation, or from the
+// exif:Orientation property if ImageMagick didn't report it
+func (details ImageDetails) ImageOrientation() Orientation {
+	if orientation := ParseOrientation(details.Orientation); orientation != OrientationUndefined {
+		return orientation
+	}
+
+	if value, err := details.Exif().Tag("Orientation"); err == nil {
+		return ParseOrientation(value)
+	}
+	return OrientationUndefined
+}
+
+// DisplayDimensions returns the width and height of the image as it is displayed, which are the
+// Geometry dimensions swapped if the image is rotated by 90 or 270 degrees.  It returns nil if the
+// image has no Geometry.
+func (details ImageDetails) DisplayDimensions() *Dimensions {
+	if details.Geometry == nil || details.Geometry.Dimensions == nil {
+		return nil
+	}
+
+	dimensions := *details.Geometry.Dimensions
+	if details.ImageOrientation().IsRotated90() {
+		dimensions.Width, dimensions.Height = dimensions.Height, dimensions.Width
+	}
+	return &dimensions
+}
@@ -0,0 +1,95 @@
+package imagemagick_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kamermans/imagemagick"
+)
+
+func TestOrientation(t *testing.T) {
+	tests := []struct {
+		name      string
+		rotated90 bool
+		mirrored  bool
+		args      []string
+	}{
+		{"TopLeft", false, false, nil},
+		{"TopRight", false, true, []string{"-flop"}},
+		{"BottomRight", false, false, []string{"-rotate", "180"}},
+		{"BottomLeft", false, true, []string{"-flop", "-rotate", "180"}},
+		{"LeftTop", true, true, []string{"-flop", "-rotate", "270"}},
+		{"RightTop", true, false, []string{"-rotate", "90"}},
+		{"RightBottom", true, true, []string{"-flop", "-rotate", "90"}},
+		{"LeftBottom", true, false, []string{"-rotate", "270"}},
+	}
+
+	for i, test := range tests {
+		orientation := imagemagick.ParseOrientation(test.name)
+		if orientation != imagemagick.Orientation(i+1) {
+			t.Fatalf("ParseOrientation(%q) failed: expected %v, got %v", test.name, i+1, int(orientation))
+		}
+		if byValue := imagemagick.ParseOrientation(string(rune('1' + i))); byValue != orientation {
+			t.Fatalf("ParseOrientation(%v) failed: expected %v, got %v", i+1, orientation, byValue)
+		}
+		if orientation.String() != test.name {
+			t.Fatalf("String() failed: expected %v, got %v", test.name, orientation)
+		}
+		if orientation.IsRotated90() != test.rotated90 {
+			t.Fatalf("IsRotated90() failed for %v", test.name)
+		}
+		if orientation.IsMirrored() != test.mirrored {
+			t.Fatalf("IsMirrored() failed for %v", test.name)
+		}
+		transform := orientation.Transform()
+		if args := transform.Args(); !reflect.DeepEqual(args, test.args) {
+			t.Fatalf("Transform() failed for %v: expected %v, got %v", test.name, test.args, args)
+		}
+		if transform.IsIdentity() != (test.args == nil) {
+			t.Fatalf("IsIdentity() failed for %v", test.name)
+		}
+	}
+
+	for _, invalid := range []string{"", "Undefined", "0", "9", "Sideways"} {
+		if orientation := imagemagick.ParseOrientation(invalid); orientation != imagemagick.OrientationUndefined {
+			t.Fatalf("ParseOrientation(%q) failed: expected Undefined, got %v", invalid, orientation)
+		}
+	}
+}
+
+func TestDisplayDimensions(t *testing.T) {
+	geometry := &imagemagick.Geometry{Dimensions: &imagemagick.Dimensions{Width: 4000, Height: 3000}}
+
+	details := imagemagick.ImageDetails{Geometry: geometry, Orientation: "RightTop"}
+	if orientation := details.ImageOrientation(); orientation != imagemagick.OrientationRightTop {
+		t.Fatalf("ImageOrientation() failed: expected RightTop, got %v", orientation)
+	}
+	if dimensions := details.DisplayDimensions(); dimensions.Width != 3000 || dimensions.Height != 4000 {
+		t.Fatalf("DisplayDimensions() failed: expected 3000x4000, got %v", dimensions)
+	}
+	if geometry.Width != 4000 {
+		t.Fatalf("DisplayDimensions() failed: the Geometry was changed")
+	}
+
+	// Falls back to exif:Orientation
+	details = imagemagick.ImageDetails{
+		Geometry:    geometry,
+		Orientation: "Undefined",
+		Properties:  map[string]string{"exif:Orientation": "8"},
+	}
+	if orientation := details.ImageOrientation(); orientation != imagemagick.OrientationLeftBottom {
+		t.Fatalf("ImageOrientation() failed: expected LeftBottom, got %v", orientation)
+	}
+	if dimensions := details.DisplayDimensions(); dimensions.Width != 3000 || dimensions.Height != 4000 {
+		t.Fatalf("DisplayDimensions() failed: expected 3000x4000, got %v", dimensions)
+	}
+
+	details = imagemagick.ImageDetails{Geometry: geometry, Orientation: "BottomRight"}
+	if dimensions := details.DisplayDimensions(); dimensions.Width != 4000 || dimensions.Height != 3000 {
+		t.Fatalf("DisplayDimensions() failed: expected 4000x3000, got %v", dimensions)
+	}
+
+	if dimensions := (imagemagick.ImageDetails{}).DisplayDimensions(); dimensions != nil {
+		t.Fatalf("DisplayDimensions() failed: expected nil without a Geometry, got %v", dimensions)
+	}
+}
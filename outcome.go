@@ -0,0 +1,211 @@
+package imagemagick
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Outcome is the result of identifying one frame of an input file, or the error that prevented
+// the file from being identified.  Exactly one of Result and Err is set.
+type Outcome struct {
+	// File is the input file, exactly as it was received
+	File string
+	// Result holds the details of one frame of the file (multi-frame files have several Outcomes)
+	Result *ImageResult
+	// Err is the error for the file
+	Err *ParserError
+}
+
+// OutcomeOrder is the order in which Outcomes are returned by IdentifyFiles
+type OutcomeOrder int
+
+const (
+	// OrderCompletion returns the Outcomes as soon as they are available
+	OrderCompletion OutcomeOrder = iota
+	// OrderInput returns the Outcomes in the order the files were received, holding back the
+	// Outcomes of files that finished early
+	OrderInput
+)
+
+// OutcomeIterator returns the Outcomes of IdentifyFiles one at a time.  Close must be called if
+// the iterator is abandoned before Next returns false, so the workers can be stopped.
+type OutcomeIterator struct {
+	outcomes  chan Outcome
+	closed    chan struct{}
+	done      chan struct{}
+	cancel    context.CancelFunc
+	closeOnce sync.Once
+}
+
+// IdentifyFiles computes ImageDetails for a channel of input files in parallel like
+// GetImageDetailsParallelContext, but returns a single stream of Outcomes that keeps each result
+// and error associated with its file, instead of separate results and errors channels, for example:
+//
+//	outcomes := parser.IdentifyFiles(ctx, files, imagemagick.OrderInput)
+//	defer outcomes.Close()
+//	for {
+//		outcome, ok := outcomes.Next()
+//		if !ok {
+//			break
+//		}
+//		if outcome.Err != nil {
+//			fmt.Printf("%v failed: %v\n", outcome.File, outcome.Err.Msg())
+//			continue
+//		}
+//		fmt.Printf("%v is a %v\n", outcome.File, outcome.Result.Image.Format)
+//	}
+//
+// The files channel should be closed when there are no more files.  Once ctx is done, the
+// workers stop reading from the files channel, so the sender should also watch ctx.Done().
+func (parser *Parser) IdentifyFiles(ctx context.Context, files <-chan string, order OutcomeOrder) *OutcomeIterator {
+	ctx, cancel := context.WithCancel(ctx)
+
+	it := &OutcomeIterator{
+		outcomes: make(chan Outcome),
+		closed:   make(chan struct{}),
+		done:     make(chan struct{}),
+		cancel:   cancel,
+	}
+
+	// send returns false once the iterator is closed
+	send := func(outcome Outcome) bool {
+		select {
+		case it.outcomes <- outcome:
+			return true
+		case <-it.closed:
+			return false
+		}
+	}
+
+	var lock sync.Mutex
+	pending := map[int][]Outcome{}
+	nextIndex := 0
+
+	// The Outcomes that are ready to be sent in order, and whether a worker is sending them
+	var queue []Outcome
+	sending := false
+
+	// complete sends the Outcomes of the file at the given position, or holds them back until
+	// the files before it are done if the input order is requested.  The Outcomes are sent without
+	// holding the lock, so only the worker that is sending waits for a slow reader.
+	complete := func(index int, outcomes []Outcome) {
+		if order != OrderInput {
+			for _, outcome := range outcomes {
+				if !send(outcome) {
+					return
+				}
+			}
+			return
+		}
+
+		lock.Lock()
+		pending[index] = outcomes
+		for {
+			ready, ok := pending[nextIndex]
+			if !ok {
+				break
+			}
+			delete(pending, nextIndex)
+			nextIndex++
+			queue = append(queue, ready...)
+		}
+
+		// The worker that is already sending sends these too, after its own
+		if sending {
+			lock.Unlock()
+			return
+		}
+
+		sending = true
+		for len(queue) > 0 {
+			ready := queue
+			queue = nil
+			lock.Unlock()
+
+			for _, outcome := range ready {
+				if !send(outcome) {
+					// The iterator is closed, so the Outcomes are dropped and sending stays set
+					return
+				}
+			}
+
+			lock.Lock()
+		}
+		sending = false
+		lock.Unlock()
+	}
+
+	go func() {
+		defer close(it.done)
+		defer close(it.outcomes)
+		defer cancel()
+
+		parser.identifyParallel(ctx, files,
+			func(index int, file string, results []*ImageResult) {
+				outcomes := make([]Outcome, 0, len(results))
+				for _, result := range results {
+					outcomes = append(outcomes, Outcome{File: file, Result: result})
+				}
+
+				if len(outcomes) == 0 {
+					outcomes = append(outcomes, Outcome{
+						File: file,
+						Err:  NewParserError("ImageMagick returned no results for the file", file, "", []byte{}, []byte{}),
+					})
+				}
+
+				complete(index, outcomes)
+			},
+			func(indexes []int, files []string, err *ParserError) {
+				for i, index := range indexes {
+					complete(index, []Outcome{{File: files[i], Err: err}})
+				}
+			},
+			// Every outcome needs its file, so batches whose results can't be matched are retried
+			nil,
+		)
+
+		// Files that were skipped because ctx was done leave gaps, so send the rest in order
+		lock.Lock()
+		indexes := make([]int, 0, len(pending))
+		for index := range pending {
+			indexes = append(indexes, index)
+		}
+		sort.Ints(indexes)
+
+		var rest []Outcome
+		for _, index := range indexes {
+			rest = append(rest, pending[index]...)
+		}
+		lock.Unlock()
+
+		for _, outcome := range rest {
+			if !send(outcome) {
+				return
+			}
+		}
+	}()
+
+	return it
+}
+
+// Next returns the next Outcome, or false when there are no more Outcomes
+func (it *OutcomeIterator) Next() (outcome Outcome, ok bool) {
+	select {
+	case outcome, ok = <-it.outcomes:
+		return
+	case <-it.closed:
+		return Outcome{}, false
+	}
+}
+
+// Close stops the workers, killing any running `convert` processes, and waits for them to exit.
+// It is safe to call Close more than once, and after Next has returned false.
+func (it *OutcomeIterator) Close() {
+	it.closeOnce.Do(func() {
+		close(it.closed)
+		it.cancel()
+	})
+	<-it.done
+}
@@ -0,0 +1,35 @@
+//go:build go1.23
+// +build go1.23
+
+package imagemagick
+
+import (
+	"context"
+	"iter"
+)
+
+// IdentifySeq is like IdentifyFiles, but returns the Outcomes as an iterator of (file, Outcome)
+// pairs for use with range, for example:
+//
+//	for file, outcome := range parser.IdentifySeq(ctx, files, imagemagick.OrderInput) {
+//		if outcome.Err != nil {
+//			fmt.Printf("%v failed: %v\n", file, outcome.Err.Msg())
+//			continue
+//		}
+//		fmt.Printf("%v is a %v\n", file, outcome.Result.Image.Format)
+//	}
+//
+// Breaking out of the loop stops the workers and kills any running `convert` processes.
+func (parser *Parser) IdentifySeq(ctx context.Context, files <-chan string, order OutcomeOrder) iter.Seq2[string, Outcome] {
+	return func(yield func(string, Outcome) bool) {
+		outcomes := parser.IdentifyFiles(ctx, files, order)
+		defer outcomes.Close()
+
+		for {
+			outcome, ok := outcomes.Next()
+			if !ok || !yield(outcome.File, outcome) {
+				return
+			}
+		}
+	}
+}
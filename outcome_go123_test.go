@@ -0,0 +1,45 @@
+//go:build go1.23
+// +build go1.23
+
+package imagemagick_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kamermans/imagemagick"
+	test "github.com/kamermans/imagemagick/test_resources"
+)
+
+func TestIdentifySeq(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperBatch")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+	parser.Workers = 2
+	parser.BatchSize = 2
+
+	files := batchTestFiles(8, 6)
+
+	i := 0
+	for file, outcome := range parser.IdentifySeq(context.Background(), sendFiles(files), imagemagick.OrderInput) {
+		if file != files[i] || outcome.File != file {
+			t.Fatalf("IdentifySeq() failed: outcome %v is for %v, expected %v", i, file, files[i])
+		}
+
+		if (i == 6) != (outcome.Err != nil) {
+			t.Fatalf("IdentifySeq() failed: unexpected error state for %v: %v", file, outcome.Err)
+		}
+
+		i++
+	}
+
+	if i != len(files) {
+		t.Fatalf("IdentifySeq() failed: expected %v outcomes, got %v", len(files), i)
+	}
+
+	// Breaking out early must stop the workers
+	for range parser.IdentifySeq(context.Background(), sendFiles(files), imagemagick.OrderCompletion) {
+		break
+	}
+}
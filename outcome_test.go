@@ -0,0 +1,147 @@
+package imagemagick_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kamermans/imagemagick"
+	test "github.com/kamermans/imagemagick/test_resources"
+)
+
+func sendFiles(files []string) <-chan string {
+	filesChan := make(chan string)
+	go func() {
+		defer close(filesChan)
+		for _, file := range files {
+			filesChan <- file
+		}
+	}()
+	return filesChan
+}
+
+func TestIdentifyFilesInputOrder(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperBatch")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+	parser.Workers = 4
+	parser.BatchSize = 3
+
+	files := batchTestFiles(20, 3, 11)
+	files[7] = "/foo/bar/multi_7.gif"
+	// Adjacent copies of a file, which usually end up in the same batch
+	files[16] = files[15]
+
+	outcomes := parser.IdentifyFiles(context.Background(), sendFiles(files), imagemagick.OrderInput)
+	defer outcomes.Close()
+
+	expected := []string{}
+	for _, file := range files {
+		expected = append(expected, file)
+		if file == "/foo/bar/multi_7.gif" {
+			expected = append(expected, file, file)
+		}
+	}
+
+	i := 0
+	for {
+		outcome, ok := outcomes.Next()
+		if !ok {
+			break
+		}
+
+		if i >= len(expected) || outcome.File != expected[i] {
+			t.Fatalf("IdentifyFiles() failed: outcome %v is for %v, expected %v", i, outcome.File, expected)
+		}
+
+		if (outcome.Err != nil) != (outcome.Result == nil) {
+			t.Fatalf("IdentifyFiles() failed: expected exactly one of Result and Err for %v", outcome.File)
+		}
+
+		if outcome.Err != nil {
+			if outcome.Err.File() != outcome.File {
+				t.Fatalf("IdentifyFiles() failed: error for %v belongs to %v", outcome.File, outcome.Err.File())
+			}
+		} else if outcome.Result.Image.Name != outcome.File {
+			t.Fatalf("IdentifyFiles() failed: result for %v belongs to %v", outcome.File, outcome.Result.Image.Name)
+		}
+
+		i++
+	}
+
+	if i != len(expected) {
+		t.Fatalf("IdentifyFiles() failed: expected %v outcomes, got %v", len(expected), i)
+	}
+}
+
+func TestIdentifyFilesCompletionOrder(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperBatch")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+	parser.Workers = 4
+	parser.BatchSize = 4
+
+	files := batchTestFiles(16, 2)
+
+	outcomes := parser.IdentifyFiles(context.Background(), sendFiles(files), imagemagick.OrderCompletion)
+	defer outcomes.Close()
+
+	seen := map[string]bool{}
+	errs := 0
+	for {
+		outcome, ok := outcomes.Next()
+		if !ok {
+			break
+		}
+
+		if seen[outcome.File] {
+			t.Fatalf("IdentifyFiles() failed: received duplicate file %v", outcome.File)
+		}
+		seen[outcome.File] = true
+
+		if outcome.Err != nil {
+			errs++
+		}
+	}
+
+	if len(seen) != len(files) || errs != 1 {
+		t.Fatalf("IdentifyFiles() failed: expected %v files with 1 error, got %v with %v", len(files), len(seen), errs)
+	}
+}
+
+func TestIdentifyFilesClose(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperBatch")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+	parser.Workers = 2
+	parser.BatchSize = 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The sender never closes the channel, so Close has to stop the workers
+	filesChan := make(chan string)
+	go func() {
+		for {
+			select {
+			case filesChan <- batchTestFiles(1)[0]:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	outcomes := parser.IdentifyFiles(ctx, filesChan, imagemagick.OrderInput)
+	if _, ok := outcomes.Next(); !ok {
+		t.Fatalf("IdentifyFiles() failed: expected an outcome")
+	}
+
+	outcomes.Close()
+	outcomes.Close()
+
+	if _, ok := outcomes.Next(); ok {
+		t.Fatalf("Next() failed: expected no more outcomes after Close()")
+	}
+}
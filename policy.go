@@ -0,0 +1,292 @@
+package imagemagick
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PolicyRule is a single <policy> element of an ImageMagick policy.xml, like:
+//
+//	<policy domain="coder" rights="none" pattern="{MVG,MSL}" />
+type PolicyRule struct {
+	// Domain is "coder", "delegate", "filter", "path", "module", "resource", "cache" or "system"
+	Domain string `xml:"domain,attr"`
+	// Rights is "none", "read", "write", "execute" or a combination like "read|write"
+	Rights string `xml:"rights,attr,omitempty"`
+	// Pattern is matched against the coder, delegate, path, etc, like "PDF" or "{PS,EPS}"
+	Pattern string `xml:"pattern,attr,omitempty"`
+	// Name and Value set a resource or system setting, like "memory" and "256MiB"
+	Name  string `xml:"name,attr,omitempty"`
+	Value string `xml:"value,attr,omitempty"`
+}
+
+// String representation
+func (rule PolicyRule) String() string {
+	parts := []string{"domain=" + rule.Domain}
+	for _, attr := range [][2]string{{"rights", rule.Rights}, {"pattern", rule.Pattern}, {"name", rule.Name}, {"value", rule.Value}} {
+		if attr[1] != "" {
+			parts = append(parts, attr[0]+"="+attr[1])
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Policy is an ImageMagick security policy, which is rendered as a policy.xml file
+type Policy struct {
+	Rules []PolicyRule
+}
+
+// The coders that can run commands, read arbitrary files or fetch URLs instead of decoding image
+// data
+var scriptCoders = []string{
+	"EPHEMERAL", "URL", "HTTP", "HTTPS", "FTP", "MVG", "MSL", "TEXT", "LABEL", "SHOW", "WIN", "PLT",
+}
+
+// The script coders, and the PostScript and PDF coders, which run ghostscript
+var dangerousCoders = append(append([]string{}, scriptCoders...),
+	"PS", "PS2", "PS3", "EPS", "EPI", "EPSF", "EPSI", "EPT", "PDF", "PDFA", "XPS",
+)
+
+// NewSecurityPolicy returns a policy for reading untrusted images: the dangerous coders (like
+// MVG, MSL, URL, EPHEMERAL and the PostScript and PDF coders) are disabled, "@" file lists can't
+// be read, and the given resource limits are applied
+func NewSecurityPolicy(limits ResourceLimits) *Policy {
+	policy := &Policy{}
+	policy.DenyCoders(dangerousCoders...)
+	policy.DenyPaths("@*")
+	policy.SetResourceLimits(limits)
+	return policy
+}
+
+// AddRule adds a rule to the policy
+func (policy *Policy) AddRule(rule PolicyRule) *Policy {
+	policy.Rules = append(policy.Rules, rule)
+	return policy
+}
+
+// DenyCoders disables reading and writing the given formats, like "MVG" or "PDF"
+func (policy *Policy) DenyCoders(coders ...string) *Policy {
+	for _, coder := range coders {
+		policy.AddRule(PolicyRule{Domain: "coder", Rights: "none", Pattern: strings.ToUpper(coder)})
+	}
+	return policy
+}
+
+// DenyDelegates disables the given external programs, like "gs" or "*" for all of them
+func (policy *Policy) DenyDelegates(delegates ...string) *Policy {
+	for _, delegate := range delegates {
+		policy.AddRule(PolicyRule{Domain: "delegate", Rights: "none", Pattern: delegate})
+	}
+	return policy
+}
+
+// DenyPaths disables reading and writing the paths that match the given patterns, like "@*"
+func (policy *Policy) DenyPaths(patterns ...string) *Policy {
+	for _, pattern := range patterns {
+		policy.AddRule(PolicyRule{Domain: "path", Rights: "none", Pattern: pattern})
+	}
+	return policy
+}
+
+// SetResource caps a resource, like SetResource("memory", "256MiB")
+func (policy *Policy) SetResource(name string, value string) *Policy {
+	return policy.AddRule(PolicyRule{Domain: "resource", Name: name, Value: value})
+}
+
+// SetResourceLimits caps the resources that are set in limits
+func (policy *Policy) SetResourceLimits(limits ResourceLimits) *Policy {
+	for _, limit := range limits.values() {
+		policy.SetResource(limit[0], limit[1])
+	}
+	return policy
+}
+
+// policyMap is the root element of policy.xml
+type policyMap struct {
+	XMLName xml.Name     `xml:"policymap"`
+	Rules   []PolicyRule `xml:"policy"`
+}
+
+// XML renders the policy as a policy.xml file
+func (policy *Policy) XML() ([]byte, error) {
+	out, err := xml.MarshalIndent(policyMap{Rules: policy.Rules}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(out, '\n')...), nil
+}
+
+// WriteFile writes the policy to policy.xml in the given directory, which can then be used as
+// Parser.ConfigurePath
+func (policy *Policy) WriteFile(dir string) error {
+	out, err := policy.XML()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "policy.xml"), out, 0644)
+}
+
+// UsePolicy writes the policy to policy.xml in a new temp directory (in Parser.TempDir if it is
+// set) and sets Parser.ConfigurePath to the directory, so the ImageMagick processes load it.  It
+// returns the directory, which should be removed when the Parser is no longer used.  Note that
+// ImageMagick also loads the system policy.xml, so a policy can only add restrictions.
+func (parser *Parser) UsePolicy(policy *Policy) (dir string, err error) {
+	dir, err = ioutil.TempDir(parser.TempDir, "imagemagick-policy")
+	if err != nil {
+		return
+	}
+
+	if err = policy.WriteFile(dir); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	parser.ConfigurePath = dir
+	return
+}
+
+// ActivePolicy is a policy rule that ImageMagick has loaded, as listed by `convert -list policy`
+type ActivePolicy struct {
+	PolicyRule
+	// Path is the policy.xml file the rule came from, like "/etc/ImageMagick-6/policy.xml"
+	Path string
+}
+
+// ParsePolicyList parses the output of `convert -list policy`, which looks like:
+//
+//	Path: /etc/ImageMagick-6/policy.xml
+//	  Policy: Coder
+//	    rights: None
+//	    pattern: MVG
+func ParsePolicyList(out []byte) (policies []*ActivePolicy) {
+	var path string
+	var current *ActivePolicy
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		key, value, ok := cutKeyValue(scanner.Text())
+		if !ok {
+			continue
+		}
+
+		switch strings.ToLower(key) {
+		case "path":
+			path = value
+			current = nil
+		case "policy":
+			current = &ActivePolicy{PolicyRule: PolicyRule{Domain: strings.ToLower(value)}, Path: path}
+			policies = append(policies, current)
+		case "rights":
+			if current != nil {
+				current.Rights = value
+			}
+		case "pattern":
+			if current != nil {
+				current.Pattern = value
+			}
+		case "name":
+			if current != nil {
+				current.Name = value
+			}
+		case "value":
+			if current != nil {
+				current.Value = value
+			}
+		}
+	}
+
+	return
+}
+
+// cutKeyValue splits a "key: value" line
+func cutKeyValue(line string) (key string, value string, ok bool) {
+	i := strings.Index(line, ":")
+	if i < 0 {
+		return "", "", false
+	}
+	return strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:]), true
+}
+
+// normalizeRights returns the rights in a comparable form, since `convert -list policy` lists
+// "read|write" as "Read Write"
+func normalizeRights(rights string) string {
+	words := strings.FieldsFunc(strings.ToLower(rights), func(r rune) bool {
+		return r < 'a' || r > 'z'
+	})
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+// matches returns true if the active policy implements the rule
+func (active *ActivePolicy) matches(rule PolicyRule) bool {
+	return strings.EqualFold(active.Domain, rule.Domain) &&
+		normalizeRights(active.Rights) == normalizeRights(rule.Rights) &&
+		strings.EqualFold(active.Pattern, rule.Pattern) &&
+		strings.EqualFold(active.Name, rule.Name) &&
+		strings.EqualFold(active.Value, rule.Value)
+}
+
+// ListPolicies returns the policy rules that ImageMagick has loaded, from `convert -list policy`
+func (parser *Parser) ListPolicies() (policies []*ActivePolicy, err *ParserError) {
+	return parser.ListPoliciesContext(context.Background())
+}
+
+// ListPoliciesContext is like ListPolicies, but the `convert` process is killed if ctx is
+// canceled or its deadline expires before it exits
+func (parser *Parser) ListPoliciesContext(ctx context.Context) (policies []*ActivePolicy, err *ParserError) {
+	stdOut, _, err := parser.ConvertContext(ctx, "-list", "policy")
+	if err != nil {
+		return
+	}
+	return ParsePolicyList(*stdOut), nil
+}
+
+// VerifyPolicy checks that every rule of the policy is active in ImageMagick, which catches a
+// wrong Parser.ConfigurePath or an ImageMagick that ignores it.  The error lists the missing rules.
+func (parser *Parser) VerifyPolicy(policy *Policy) *ParserError {
+	return parser.VerifyPolicyContext(context.Background(), policy)
+}
+
+// VerifyPolicyContext is like VerifyPolicy, but the `convert` process is killed if ctx is
+// canceled or its deadline expires before it exits
+func (parser *Parser) VerifyPolicyContext(ctx context.Context, policy *Policy) *ParserError {
+	active, err := parser.ListPoliciesContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, rule := range policy.Rules {
+		found := false
+		for _, a := range active {
+			if a.matches(rule) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			missing = append(missing, rule.String())
+		}
+	}
+
+	if len(missing) > 0 {
+		return NewParserError(
+			fmt.Sprintf("ImageMagick policy is not active, missing rules: %v", strings.Join(missing, "; ")),
+			"",
+			parser.commandString([]string{"-list", "policy"}),
+			[]byte{},
+			[]byte{},
+		)
+	}
+
+	return nil
+}
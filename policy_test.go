@@ -0,0 +1,154 @@
+package imagemagick_test
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kamermans/imagemagick"
+	test "github.com/kamermans/imagemagick/test_resources"
+)
+
+const samplePolicyList = `
+Path: /etc/ImageMagick-6/policy.xml
+  Policy: Resource
+    name: memory
+    value: 256MiB
+  Policy: Coder
+    rights: None 
+    pattern: PDF
+  Policy: Path
+    rights: Read Write 
+    pattern: /tmp/*
+
+Path: [built-in]
+  Policy: Undefined
+    rights: None 
+`
+
+func TestParsePolicyList(t *testing.T) {
+	policies := imagemagick.ParsePolicyList([]byte(samplePolicyList))
+	if len(policies) != 4 {
+		t.Fatalf("ParsePolicyList() failed: expected 4 policies, got %v", len(policies))
+	}
+
+	expected := []imagemagick.PolicyRule{
+		{Domain: "resource", Name: "memory", Value: "256MiB"},
+		{Domain: "coder", Rights: "None", Pattern: "PDF"},
+		{Domain: "path", Rights: "Read Write", Pattern: "/tmp/*"},
+		{Domain: "undefined", Rights: "None"},
+	}
+	for i, rule := range expected {
+		if policies[i].PolicyRule != rule {
+			t.Fatalf("ParsePolicyList() failed: expected %v, got %v", rule, policies[i].PolicyRule)
+		}
+	}
+
+	if policies[0].Path != "/etc/ImageMagick-6/policy.xml" || policies[3].Path != "[built-in]" {
+		t.Fatalf("ParsePolicyList() failed: wrong paths %v, %v", policies[0].Path, policies[3].Path)
+	}
+}
+
+func TestPolicyXML(t *testing.T) {
+	policy := &imagemagick.Policy{}
+	policy.DenyCoders("mvg", "MSL").SetResource("memory", "256MiB")
+	policy.AddRule(imagemagick.PolicyRule{Domain: "path", Rights: "read|write", Pattern: "/tmp/*"})
+
+	out, err := policy.XML()
+	if err != nil {
+		t.Fatalf("Policy.XML() failed: %v", err)
+	}
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<policymap>
+  <policy domain="coder" rights="none" pattern="MVG"></policy>
+  <policy domain="coder" rights="none" pattern="MSL"></policy>
+  <policy domain="resource" name="memory" value="256MiB"></policy>
+  <policy domain="path" rights="read|write" pattern="/tmp/*"></policy>
+</policymap>
+`
+	if string(out) != expected {
+		t.Fatalf("Policy.XML() failed: expected %v, got %v", expected, string(out))
+	}
+}
+
+// TestHelperListPolicy mocks `convert -list policy`, listing the rules of the policy.xml in
+// MAGICK_CONFIGURE_PATH
+func TestHelperListPolicy(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	path := filepath.Join(os.Getenv("MAGICK_CONFIGURE_PATH"), "policy.xml")
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Println("Path: [built-in]\n  Policy: Undefined\n    rights: None ")
+		return
+	}
+
+	var policyMap struct {
+		Rules []imagemagick.PolicyRule `xml:"policy"`
+	}
+	if err := xml.Unmarshal(content, &policyMap); err != nil {
+		os.Exit(1)
+	}
+
+	fmt.Printf("Path: %v\n", path)
+	for _, rule := range policyMap.Rules {
+		fmt.Printf("  Policy: %v\n", capitalize(rule.Domain))
+		if rule.Rights != "" {
+			fmt.Printf("    rights: %v \n", capitalize(strings.Replace(rule.Rights, "|", " ", -1)))
+		}
+		for _, attr := range [][2]string{{"pattern", rule.Pattern}, {"name", rule.Name}, {"value", rule.Value}} {
+			if attr[1] != "" {
+				fmt.Printf("    %v: %v\n", attr[0], attr[1])
+			}
+		}
+	}
+}
+
+// capitalize upper-cases the first letter of each word, like ImageMagick lists policy values
+func capitalize(s string) string {
+	words := strings.Fields(s)
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+func TestVerifyPolicy(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperListPolicy")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+
+	policy := imagemagick.NewSecurityPolicy(imagemagick.ResourceLimits{Memory: 1 << 28, Width: 10000})
+
+	if err := parser.VerifyPolicy(policy); err == nil {
+		t.Fatalf("VerifyPolicy() did not fail as expected without the policy")
+	}
+
+	dir, err := parser.UsePolicy(policy)
+	if err != nil {
+		t.Fatalf("UsePolicy() failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if parser.ConfigurePath != dir {
+		t.Fatalf("UsePolicy() failed: expected ConfigurePath %v, got %v", dir, parser.ConfigurePath)
+	}
+
+	if err := parser.VerifyPolicy(policy); err != nil {
+		t.Fatalf("VerifyPolicy() failed: %v", err.Error())
+	}
+
+	policy.DenyDelegates("gs")
+	if err := parser.VerifyPolicy(policy); err == nil || !strings.Contains(err.Msg(), "domain=delegate rights=none pattern=gs") {
+		t.Fatalf("VerifyPolicy() did not report the missing rule: %v", err)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build go1.20
+// +build go1.20
+
+package imagemagick
+
+import (
+	"os/exec"
+	"time"
+)
+
+// waitDelay is how long Wait waits for stdout and stderr to be closed after the command exits,
+// since a delegate that left the process group may keep them open
+const waitDelay = 2 * time.Second
+
+// setWaitDelay keeps Wait from hanging on the output of a delegate that outlives the command
+func setWaitDelay(cmd *exec.Cmd) {
+	cmd.WaitDelay = waitDelay
+}
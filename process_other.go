@@ -0,0 +1,17 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package imagemagick
+
+import "os/exec"
+
+// setProcessGroup is a no-op on systems without POSIX process groups
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the command; child processes are not tracked on this system
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	cmd.Process.Kill()
+}
@@ -0,0 +1,9 @@
+//go:build !go1.20
+// +build !go1.20
+
+package imagemagick
+
+import "os/exec"
+
+// setWaitDelay is a no-op before Go 1.20, which added exec.Cmd.WaitDelay
+func setWaitDelay(cmd *exec.Cmd) {}
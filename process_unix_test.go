@@ -0,0 +1,68 @@
+//go:build go1.20 && (aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris)
+// +build go1.20
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package imagemagick_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/kamermans/imagemagick"
+	test "github.com/kamermans/imagemagick/test_resources"
+)
+
+func TestConvertContextDetachedDelegate(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperDetachedDelegate")
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	startTime := time.Now()
+	_, stdErr, err := parser.ConvertContext(ctx, "foo.ps", "bar.png")
+	elapsed := time.Since(startTime)
+
+	// Clean up the delegate, which is not in the process group
+	if pid, convErr := strconv.Atoi(strings.TrimSpace(string(*stdErr))); convErr == nil {
+		syscall.Kill(pid, syscall.SIGKILL)
+	}
+
+	if err == nil || !err.Timeout() {
+		t.Fatalf("ConvertContext() failed: expected a timeout error, got %v", err)
+	}
+
+	if elapsed > 10*time.Second {
+		t.Fatalf("ConvertContext() failed: waited %v for the output of the delegate", elapsed)
+	}
+}
+
+// TestHelperDetachedDelegate starts a delegate in its own session that keeps stdout and stderr
+// open after the command is killed
+func TestHelperDetachedDelegate(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	delegate := exec.Command(os.Args[0], "-test.run=TestHelperSlowCommand")
+	delegate.Env = []string{"GO_WANT_HELPER_PROCESS=1"}
+	delegate.Stdout = os.Stdout
+	delegate.Stderr = os.Stderr
+	delegate.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := delegate.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to start the delegate: %v", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "%d\n", delegate.Process.Pid)
+
+	time.Sleep(30 * time.Second)
+}
@@ -0,0 +1,263 @@
+package imagemagick
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ErrProfileNotFound is returned when the image doesn't have the requested profile
+var ErrProfileNotFound = errors.New("imagemagick: profile not found")
+
+// ImageMagick reports a missing profile like "no IPTC profile available" or "No 8BIM data is available"
+var noProfilePattern = regexp.MustCompile(`(?i)\bno\b[^\n]*\b(profile|data)\b[^\n]*\bavailable\b`)
+
+// The profiles that ExtractProfile can extract, keyed by their lowercase name
+var extractableProfiles = map[string]bool{
+	"8bim": true,
+	"exif": true,
+	"icc":  true,
+	"iptc": true,
+	"xmp":  true,
+}
+
+// ExtractProfile returns the raw bytes of an embedded profile from the first frame of the file,
+// which can be "icc", "exif", "iptc", "xmp" or "8bim" (case-insensitive).  The error matches
+// ErrProfileNotFound with errors.Is() if the file doesn't have the profile.  The file name is taken
+// literally, as with GetImageDetailsWithOptions.
+func (parser *Parser) ExtractProfile(file string, name string) (profile []byte, err *ParserError) {
+	return parser.ExtractProfileContext(context.Background(), file, name)
+}
+
+// ExtractProfileContext is like ExtractProfile, but the `convert` process is killed if ctx is
+// canceled or its deadline expires before it exits
+func (parser *Parser) ExtractProfileContext(ctx context.Context, file string, name string) (profile []byte, err *ParserError) {
+	if !extractableProfiles[strings.ToLower(name)] {
+		err = NewParserError(fmt.Sprintf("Invalid profile name: %q", name), file, "", []byte{}, []byte{})
+		return
+	}
+	return parser.extractProfile(ctx, file, name)
+}
+
+// extractProfile returns the raw bytes of an embedded profile from the first frame of the file, like
+// "iptc" or "xmp", by writing it to stdout with the ImageMagick format of the same name
+func (parser *Parser) extractProfile(ctx context.Context, file string, name string) (profile []byte, err *ParserError) {
+	input, checkErr := parser.checkInput(file)
+	if checkErr != nil {
+		err = inputError(file, checkErr)
+		return
+	}
+
+	// Compose command like this:
+	//   "convert file[0] iptc:-"
+	args := []string{frameInput(input, "0"), strings.ToUpper(name) + ":-"}
+
+	var stdout, stderr bytes.Buffer
+	if cmdErr := parser.run(ctx, nil, &stdout, &stderr, args...); cmdErr != nil {
+		err = parser.newCommandError(cmdErr, file, args, stdout.Bytes(), stderr.Bytes())
+		if noProfilePattern.Match(stderr.Bytes()) {
+			err.cause = fmt.Errorf("%w: %v", ErrProfileNotFound, name)
+		}
+		return
+	}
+
+	if stdout.Len() == 0 {
+		err = NewParserError(
+			fmt.Sprintf("The image has no %v profile", name),
+			file,
+			parser.commandString(args),
+			stdout.Bytes(),
+			stderr.Bytes(),
+		)
+		err.cause = fmt.Errorf("%w: %v", ErrProfileNotFound, name)
+		return
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// Profile names for StripProfiles, like "icc" or "8bim"
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+// ProfileResult reports the file sizes of a profile operation like StripProfiles
+type ProfileResult struct {
+	InputSize  int64
+	OutputSize int64
+}
+
+// Saved returns the number of bytes saved, which is negative if the output file is bigger
+func (result *ProfileResult) Saved() int64 {
+	return result.InputSize - result.OutputSize
+}
+
+// StripProfiles writes the image to out without its embedded profiles, except the ones in keep,
+// like StripProfiles(in, out, "icc") to keep the color profile but drop the EXIF, IPTC, XMP and
+// 8BIM profiles.  Note that the image is re-encoded, so the output size also depends on the
+// output format and quality.  The input file name is taken literally, as with
+// GetImageDetailsWithOptions.
+func (parser *Parser) StripProfiles(in string, out string, keep ...string) (result *ProfileResult, err *ParserError) {
+	return parser.StripProfilesContext(context.Background(), in, out, keep...)
+}
+
+// StripProfilesContext is like StripProfiles, but the `convert` process is killed if ctx is
+// canceled or its deadline expires before it exits
+func (parser *Parser) StripProfilesContext(ctx context.Context, in string, out string, keep ...string) (result *ProfileResult, err *ParserError) {
+	// Compose command like this:
+	//   "convert in +profile !icc,!xmp,* out"
+	patterns := make([]string, 0, len(keep)+1)
+	for _, name := range keep {
+		if !profileNamePattern.MatchString(name) {
+			return nil, NewParserError(fmt.Sprintf("Invalid profile name: %q", name), in, "", []byte{}, []byte{})
+		}
+		patterns = append(patterns, "!"+strings.ToLower(name))
+	}
+	patterns = append(patterns, "*")
+
+	return parser.profileOperation(ctx, in, out, func(b *ConvertBuilder) {
+		b.Operator("+profile", strings.Join(patterns, ","))
+	})
+}
+
+// EmbedProfile writes the image to out with the given ICC profile, replacing the embedded one (if
+// any) without converting the pixels, which is how a missing or broken profile is fixed.  Use
+// ConvertToSRGB to convert the colors instead.  The profile is checked with ParseICCProfile.
+func (parser *Parser) EmbedProfile(in string, out string, icc []byte) (result *ProfileResult, err *ParserError) {
+	return parser.EmbedProfileContext(context.Background(), in, out, icc)
+}
+
+// EmbedProfileContext is like EmbedProfile, but the `convert` process is killed if ctx is canceled
+// or its deadline expires before it exits
+func (parser *Parser) EmbedProfileContext(ctx context.Context, in string, out string, icc []byte) (result *ProfileResult, err *ParserError) {
+	if _, parseErr := ParseICCProfile(icc); parseErr != nil {
+		err = NewParserError(parseErr.Error(), in, "", []byte{}, []byte{})
+		err.cause = parseErr
+		return
+	}
+
+	iccFile, tempErr := parser.writeTempFile("imagemagick-*.icc", icc)
+	if tempErr != nil {
+		err = NewParserError(fmt.Sprintf("Unable to write the ICC profile: %v", tempErr), in, "", []byte{}, []byte{})
+		err.cause = tempErr
+		return
+	}
+	defer os.Remove(iccFile)
+
+	// Compose command like this:
+	//   "convert in +profile icc -profile /tmp/profile.icc out"
+	return parser.profileOperation(ctx, in, out, func(b *ConvertBuilder) {
+		b.Operator("+profile", "icc").Operator("-profile", iccFile)
+	})
+}
+
+// ConvertToSRGB writes the image to out with its colors converted to sRGB.  With
+// Parser.SRGBProfile, the colors are converted from the embedded ICC profile to the sRGB profile,
+// which is embedded in the output.  ImageMagick only converts the pixels with -profile if the image
+// already has a profile, so an image without one (like a CMYK image with no profile) is first
+// converted with `-colorspace sRGB` and then gets the sRGB profile, which costs an extra `convert`
+// run to check for the profile.  Without Parser.SRGBProfile the colorspace is converted with
+// `-colorspace sRGB` and the embedded profile is removed, since it no longer matches the pixels.
+func (parser *Parser) ConvertToSRGB(in string, out string) (result *ProfileResult, err *ParserError) {
+	return parser.ConvertToSRGBContext(context.Background(), in, out)
+}
+
+// ConvertToSRGBContext is like ConvertToSRGB, but the `convert` processes are killed if ctx is
+// canceled or its deadline expires before they exit
+func (parser *Parser) ConvertToSRGBContext(ctx context.Context, in string, out string) (result *ProfileResult, err *ParserError) {
+	if parser.SRGBProfile == "" {
+		// Compose command like this:
+		//   "convert in +profile icc -colorspace sRGB out"
+		return parser.profileOperation(ctx, in, out, func(b *ConvertBuilder) {
+			b.Operator("+profile", "icc").Colorspace("sRGB")
+		})
+	}
+
+	hasProfile := true
+	if _, err = parser.extractProfile(ctx, in, "icc"); err != nil {
+		if !errors.Is(err, ErrProfileNotFound) {
+			return
+		}
+		hasProfile = false
+	}
+
+	// Compose command like this:
+	//   "convert in [-colorspace sRGB] -profile sRGB.icc out"
+	return parser.profileOperation(ctx, in, out, func(b *ConvertBuilder) {
+		if !hasProfile {
+			b.Colorspace("sRGB")
+		}
+		b.Operator("-profile", parser.SRGBProfile)
+	})
+}
+
+// profileOperation runs `convert in <operators> out` and reports the file sizes
+func (parser *Parser) profileOperation(ctx context.Context, in string, out string, operators func(b *ConvertBuilder)) (result *ProfileResult, err *ParserError) {
+	input, checkErr := parser.checkInput(in)
+	if checkErr != nil {
+		err = inputError(in, checkErr)
+		return
+	}
+
+	// Keep names like "-in.jpg" and "-out.jpg" from being read as an option, and "-" from reading
+	// stdin or writing to stdout
+	if strings.HasPrefix(input, "-") {
+		input = "./" + input
+	}
+	output := out
+	if strings.HasPrefix(output, "-") {
+		output = "./" + output
+	}
+
+	inputInfo, statErr := os.Stat(parser.localPath(in))
+	if statErr != nil {
+		err = NewParserError(fmt.Sprintf("Unable to read the input file: %v", statErr), in, "", []byte{}, []byte{})
+		err.cause = statErr
+		return
+	}
+
+	builder := NewConvertBuilder().Input(frameInput(input, ""))
+	operators(builder)
+	builder.Output(output)
+
+	if _, _, err = parser.ConvertWithContext(ctx, builder); err != nil {
+		err.file = in
+		return
+	}
+
+	outputInfo, statErr := os.Stat(parser.localPath(coderPrefixPattern.ReplaceAllString(output, "")))
+	if statErr != nil {
+		err = NewParserError(fmt.Sprintf("Unable to read the output file: %v", statErr), out, "", []byte{}, []byte{})
+		err.cause = statErr
+		return
+	}
+
+	return &ProfileResult{InputSize: inputInfo.Size(), OutputSize: outputInfo.Size()}, nil
+}
+
+// writeTempFile writes data to a new file in Parser.TempDir (or the system temp directory) and
+// returns its absolute path, which the caller must remove
+func (parser *Parser) writeTempFile(pattern string, data []byte) (path string, err error) {
+	file, err := ioutil.TempFile(parser.TempDir, pattern)
+	if err != nil {
+		return
+	}
+
+	path = file.Name()
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		path, err = filepath.Abs(path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return
+}
@@ -0,0 +1,220 @@
+package imagemagick_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kamermans/imagemagick"
+	test "github.com/kamermans/imagemagick/test_resources"
+)
+
+// TestHelperExtractProfile mocks `convert file[0] PROFILE:-`
+func TestHelperExtractProfile(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	args := helperArgs()
+	if len(args) != 2 || !strings.HasSuffix(args[0], "[0]") {
+		fmt.Fprintf(os.Stderr, "unexpected args: %v", args)
+		os.Exit(1)
+	}
+
+	if strings.Contains(args[0], "noprofile") {
+		fmt.Fprintf(os.Stderr, "convert: no %v profile available `%v' @ error/meta.c/WriteMETAImage/2264.\n", strings.TrimSuffix(args[1], ":-"), args[1])
+		os.Exit(1)
+	}
+
+	switch args[1] {
+	case "IPTC:-":
+		os.Stdout.Write(testIPTCProfile())
+	case "XMP:-":
+		fmt.Print(testXMPPacket)
+	case "ICC:-":
+		os.Stdout.Write(iccProfile("RGB ", 2, iccDescV2("sRGB IEC61966-2.1")))
+	default:
+		fmt.Fprintf(os.Stderr, "unexpected output: %v", args[1])
+		os.Exit(1)
+	}
+}
+
+func TestExtractProfile(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperExtractProfile")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+
+	data, err := parser.ExtractProfile("/foo/photo.jpg", "icc")
+	if err != nil {
+		t.Fatalf("ExtractProfile() failed: %v", err.Error())
+	}
+	if profile, parseErr := imagemagick.ParseICCProfile(data); parseErr != nil || !profile.IsSRGB() {
+		t.Fatalf("ExtractProfile() failed: unexpected ICC profile %v", parseErr)
+	}
+
+	if _, err = parser.ExtractProfile("/foo/noprofile.jpg", "8BIM"); err == nil || !errors.Is(err, imagemagick.ErrProfileNotFound) {
+		t.Fatalf("ExtractProfile() failed: expected ErrProfileNotFound, got %v", err)
+	}
+
+	if _, err = parser.ExtractProfile("/foo/photo.jpg", "json"); err == nil {
+		t.Fatalf("ExtractProfile() failed: expected an error for an invalid profile name")
+	}
+	if mockExec.RunCount() != 2 {
+		t.Fatalf("ExtractProfile() failed: expected 2 runs, got %v", mockExec.RunCount())
+	}
+}
+
+// TestHelperProfileOperation mocks `convert in <operators> out`, writing 600 bytes to out, and
+// `convert in[0] ICC:-` like TestHelperExtractProfile
+func TestHelperProfileOperation(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	args := helperArgs()
+	if len(args) == 2 && args[1] == "ICC:-" {
+		if strings.Contains(args[0], "noprofile") {
+			fmt.Fprintf(os.Stderr, "convert: no ICC profile available `ICC:-' @ error/meta.c/WriteMETAImage/2264.\n")
+			os.Exit(1)
+		}
+		os.Stdout.Write(iccProfile("CMYK", 2, iccDescV2("U.S. Web Coated (SWOP) v2")))
+		return
+	}
+	if len(args) < 3 {
+		fmt.Fprintf(os.Stderr, "unexpected args: %v", args)
+		os.Exit(1)
+	}
+
+	// The ICC profile file must exist while convert runs
+	for i, arg := range args {
+		if arg == "-profile" && strings.HasSuffix(args[i+1], ".icc") {
+			if _, err := os.Stat(args[i+1]); err != nil {
+				fmt.Fprintf(os.Stderr, "convert: unable to open file `%v' @ error/blob.c/OpenBlob/2924.\n", args[i+1])
+				os.Exit(1)
+			}
+		}
+	}
+
+	if err := ioutil.WriteFile(args[len(args)-1], make([]byte, 600), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to write output: %v", err)
+		os.Exit(1)
+	}
+}
+
+func TestProfileOperations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imagemagick-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, "in.jpg")
+	if err := ioutil.WriteFile(in, make([]byte, 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out.jpg")
+
+	mockExec := test.NewMockExec("TestHelperProfileOperation")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+	parser.TempDir = dir
+
+	result, parserErr := parser.StripProfiles(in, out, "icc", "XMP")
+	if parserErr != nil {
+		t.Fatalf("StripProfiles() failed: %v", parserErr.Error())
+	}
+	if result.InputSize != 1000 || result.OutputSize != 600 || result.Saved() != 400 {
+		t.Fatalf("StripProfiles() failed: unexpected result %+v", result)
+	}
+	if args := mockExec.LastRun().Args(); !reflect.DeepEqual(args, []string{in, "+profile", "!icc,!xmp,*", out}) {
+		t.Fatalf("StripProfiles() failed: unexpected args %v", args)
+	}
+
+	if _, parserErr = parser.StripProfiles(in, out); parserErr != nil {
+		t.Fatalf("StripProfiles() failed: %v", parserErr.Error())
+	}
+	if args := mockExec.LastRun().Args(); !reflect.DeepEqual(args, []string{in, "+profile", "*", out}) {
+		t.Fatalf("StripProfiles() failed: unexpected args %v", args)
+	}
+
+	// Names starting with "-" are not read as options
+	if err := ioutil.WriteFile(filepath.Join(dir, "-in.jpg"), make([]byte, 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	parser.Dir = dir
+	if _, parserErr = parser.StripProfiles("-in.jpg", "-out.jpg"); parserErr != nil {
+		t.Fatalf("StripProfiles() failed: %v", parserErr.Error())
+	}
+	if args := mockExec.LastRun().Args(); !reflect.DeepEqual(args, []string{"./-in.jpg", "+profile", "*", "./-out.jpg"}) {
+		t.Fatalf("StripProfiles() failed: unexpected args %v", args)
+	}
+	parser.Dir = ""
+
+	if _, parserErr = parser.StripProfiles(in, out, "icc,*"); parserErr == nil {
+		t.Fatalf("StripProfiles() failed: expected an error for an invalid profile name")
+	}
+
+	result, parserErr = parser.EmbedProfile(in, out, iccProfile("RGB ", 2, iccDescV2("sRGB IEC61966-2.1")))
+	if parserErr != nil {
+		t.Fatalf("EmbedProfile() failed: %v", parserErr.Error())
+	}
+	args := mockExec.LastRun().Args()
+	if len(args) != 6 || args[1] != "+profile" || args[2] != "icc" || args[3] != "-profile" || filepath.Dir(args[4]) != dir {
+		t.Fatalf("EmbedProfile() failed: unexpected args %v", args)
+	}
+	if _, err := os.Stat(args[4]); !os.IsNotExist(err) {
+		t.Fatalf("EmbedProfile() failed: the ICC profile file was not removed")
+	}
+	if result.Saved() != 400 {
+		t.Fatalf("EmbedProfile() failed: unexpected result %+v", result)
+	}
+
+	if _, parserErr = parser.EmbedProfile(in, out, []byte("not a profile")); parserErr == nil {
+		t.Fatalf("EmbedProfile() failed: expected an error for an invalid profile")
+	}
+
+	if _, parserErr = parser.ConvertToSRGB(in, out); parserErr != nil {
+		t.Fatalf("ConvertToSRGB() failed: %v", parserErr.Error())
+	}
+	if args := mockExec.LastRun().Args(); !reflect.DeepEqual(args, []string{in, "+profile", "icc", "-colorspace", "sRGB", out}) {
+		t.Fatalf("ConvertToSRGB() failed: unexpected args %v", args)
+	}
+
+	parser.SRGBProfile = "/usr/share/color/icc/sRGB.icm"
+	runs := mockExec.RunCount()
+	if _, parserErr = parser.ConvertToSRGB(in, out); parserErr != nil {
+		t.Fatalf("ConvertToSRGB() failed: %v", parserErr.Error())
+	}
+	if args := mockExec.LastRun().Args(); !reflect.DeepEqual(args, []string{in, "-profile", "/usr/share/color/icc/sRGB.icm", out}) {
+		t.Fatalf("ConvertToSRGB() failed: unexpected args %v", args)
+	}
+	if mockExec.RunCount() != runs+2 {
+		t.Fatalf("ConvertToSRGB() failed: expected the ICC profile to be checked first")
+	}
+
+	// Without an embedded profile, -profile would only tag the pixels, so they're converted first
+	noProfile := filepath.Join(dir, "noprofile.jpg")
+	if err := ioutil.WriteFile(noProfile, make([]byte, 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, parserErr = parser.ConvertToSRGB(noProfile, out); parserErr != nil {
+		t.Fatalf("ConvertToSRGB() failed: %v", parserErr.Error())
+	}
+	expected := []string{noProfile, "-colorspace", "sRGB", "-profile", "/usr/share/color/icc/sRGB.icm", out}
+	if args := mockExec.LastRun().Args(); !reflect.DeepEqual(args, expected) {
+		t.Fatalf("ConvertToSRGB() failed: unexpected args %v", args)
+	}
+
+	if _, parserErr = parser.ConvertToSRGB(filepath.Join(dir, "missing.jpg"), out); parserErr == nil {
+		t.Fatalf("ConvertToSRGB() failed: expected an error for a missing input file")
+	}
+}
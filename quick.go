@@ -0,0 +1,628 @@
+package imagemagick
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// errQuickUnsupported is returned when a file can't be decoded in Go, so `convert` is used instead
+var errQuickUnsupported = errors.New("format not supported by QuickDetails")
+
+// Stop walking the frames of corrupt or malicious files at some point
+const maxQuickFrames = 10000
+
+// quickFrame is the header information of one frame
+type quickFrame struct {
+	width      int64
+	height     int64
+	x          int64
+	y          int64
+	depth      int64
+	colorspace string
+}
+
+// quickHeader is the header information of an image file, decoded in Go
+type quickHeader struct {
+	coder    string
+	mimeType string
+	// The canvas size, when the frames can be smaller than the image (GIF and animated WebP)
+	pageWidth  int64
+	pageHeight int64
+	frames     []quickFrame
+}
+
+// quickImageDetails decodes the headers of a JPEG, PNG, GIF, WebP, BMP or TIFF file, returning one
+// result per frame like `convert` does.  Relative names are opened in Parser.Dir, like `convert`
+// would.
+func (parser *Parser) quickImageDetails(file string) (results []*ImageResult, err error) {
+	f, err := os.Open(parser.localPath(file))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return
+	}
+
+	header, err := decodeQuickHeader(f, info.Size())
+	if err != nil {
+		return
+	}
+
+	baseName := filepath.Base(file)
+	baseName = strings.TrimSuffix(baseName, filepath.Ext(baseName))
+
+	for i, frame := range header.frames {
+		pageWidth, pageHeight := header.pageWidth, header.pageHeight
+		if pageWidth == 0 || pageHeight == 0 {
+			pageWidth, pageHeight = frame.width, frame.height
+		}
+
+		results = append(results, &ImageResult{
+			Image: &ImageDetails{
+				Name:         file,
+				BaseName:     baseName,
+				Format:       header.coder,
+				MimeType:     header.mimeType,
+				Colorspace:   frame.colorspace,
+				Depth:        frame.depth,
+				BaseDepth:    frame.depth,
+				Filesize:     fmt.Sprintf("%dB", info.Size()),
+				NumberPixels: frame.width * frame.height,
+				Scene:        int64(i),
+				Scenes:       int64(len(header.frames)),
+				Geometry: &Geometry{
+					Point:      &Point{X: frame.x, Y: frame.y},
+					Dimensions: &Dimensions{Width: frame.width, Height: frame.height},
+				},
+				PageGeometry: &Geometry{
+					Point:      &Point{X: 0, Y: 0},
+					Dimensions: &Dimensions{Width: pageWidth, Height: pageHeight},
+				},
+			},
+			File:  file,
+			Frame: i,
+		})
+	}
+
+	return
+}
+
+// decodeQuickHeader sniffs the format of the data and decodes its header
+func decodeQuickHeader(r io.ReaderAt, size int64) (header *quickHeader, err error) {
+	coder, mimeType, err := SniffFormat(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return
+	}
+
+	stream := bufio.NewReader(io.NewSectionReader(r, 0, size))
+	switch coder {
+	case "JPEG":
+		header, err = decodeJPEGHeader(stream)
+	case "PNG":
+		header, err = decodePNGHeader(stream)
+	case "GIF":
+		header, err = decodeGIFHeader(stream)
+	case "WEBP":
+		header, err = decodeWebPHeader(stream)
+	case "BMP":
+		header, err = decodeBMPHeader(stream)
+	case "TIFF":
+		header, err = decodeTIFFHeader(r, size)
+	default:
+		return nil, errQuickUnsupported
+	}
+
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(header.frames) == 0 {
+		return nil, errQuickUnsupported
+	}
+	for _, frame := range header.frames {
+		if frame.width <= 0 || frame.height <= 0 {
+			return nil, fmt.Errorf("invalid %v dimensions: %vx%v", coder, frame.width, frame.height)
+		}
+	}
+
+	header.coder = coder
+	header.mimeType = mimeType
+	return
+}
+
+// skip discards n bytes from r
+func skip(r *bufio.Reader, n int64) error {
+	_, err := io.CopyN(ioutil.Discard, r, n)
+	return err
+}
+
+// decodeJPEGHeader finds the start of frame (SOF) marker segment
+func decodeJPEGHeader(r *bufio.Reader) (*quickHeader, error) {
+	if err := skip(r, 2); err != nil {
+		return nil, err
+	}
+
+	for {
+		// Markers are 0xFF followed by the marker code, with any number of 0xFF fill bytes
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b != 0xFF {
+			return nil, fmt.Errorf("invalid JPEG marker: %#x", b)
+		}
+
+		marker := byte(0xFF)
+		for marker == 0xFF {
+			if marker, err = r.ReadByte(); err != nil {
+				return nil, err
+			}
+		}
+
+		// Markers without a segment
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD8) {
+			continue
+		}
+
+		var length uint16
+		if err = binary.Read(r, binary.BigEndian, &length); err != nil {
+			return nil, err
+		}
+		if length < 2 {
+			return nil, fmt.Errorf("invalid JPEG segment length: %v", length)
+		}
+
+		// SOF0 to SOF15, except DHT (0xC4), JPG (0xC8) and DAC (0xCC)
+		if marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC {
+			var sof struct {
+				Precision  uint8
+				Height     uint16
+				Width      uint16
+				Components uint8
+			}
+			if err = binary.Read(r, binary.BigEndian, &sof); err != nil {
+				return nil, err
+			}
+
+			colorspace := "sRGB"
+			switch sof.Components {
+			case 1:
+				colorspace = "Gray"
+			case 4:
+				colorspace = "CMYK"
+			}
+
+			return &quickHeader{frames: []quickFrame{{
+				width:      int64(sof.Width),
+				height:     int64(sof.Height),
+				depth:      int64(sof.Precision),
+				colorspace: colorspace,
+			}}}, nil
+		}
+
+		// The image data starts without a SOF, or the image ends
+		if marker == 0xDA || marker == 0xD9 {
+			return nil, errors.New("JPEG start of frame not found")
+		}
+
+		if err = skip(r, int64(length)-2); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// decodePNGHeader reads the IHDR chunk, which must be the first chunk
+func decodePNGHeader(r *bufio.Reader) (*quickHeader, error) {
+	if err := skip(r, 8); err != nil {
+		return nil, err
+	}
+
+	var ihdr struct {
+		Length    uint32
+		Type      [4]byte
+		Width     uint32
+		Height    uint32
+		BitDepth  uint8
+		ColorType uint8
+	}
+	if err := binary.Read(r, binary.BigEndian, &ihdr); err != nil {
+		return nil, err
+	}
+	if string(ihdr.Type[:]) != "IHDR" {
+		return nil, errors.New("PNG IHDR chunk not found")
+	}
+
+	colorspace := "sRGB"
+	if ihdr.ColorType == 0 || ihdr.ColorType == 4 {
+		colorspace = "Gray"
+	}
+
+	depth := int64(ihdr.BitDepth)
+	if ihdr.ColorType == 3 {
+		// Palette indexes are expanded to 8 bit colors
+		depth = 8
+	}
+
+	return &quickHeader{frames: []quickFrame{{
+		width:      int64(ihdr.Width),
+		height:     int64(ihdr.Height),
+		depth:      depth,
+		colorspace: colorspace,
+	}}}, nil
+}
+
+// skipGIFSubBlocks skips a sequence of GIF data sub-blocks, which ends with an empty block
+func skipGIFSubBlocks(r *bufio.Reader) error {
+	for {
+		size, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if size == 0 {
+			return nil
+		}
+		if err = skip(r, int64(size)); err != nil {
+			return err
+		}
+	}
+}
+
+// decodeGIFHeader reads the logical screen descriptor and the image descriptor of every frame
+func decodeGIFHeader(r *bufio.Reader) (*quickHeader, error) {
+	if err := skip(r, 6); err != nil {
+		return nil, err
+	}
+
+	var screen struct {
+		Width            uint16
+		Height           uint16
+		Flags            uint8
+		BackgroundIndex  uint8
+		PixelAspectRatio uint8
+	}
+	if err := binary.Read(r, binary.LittleEndian, &screen); err != nil {
+		return nil, err
+	}
+
+	// Global color table
+	if screen.Flags&0x80 != 0 {
+		if err := skip(r, 3<<(screen.Flags&0x07+1)); err != nil {
+			return nil, err
+		}
+	}
+
+	header := &quickHeader{
+		pageWidth:  int64(screen.Width),
+		pageHeight: int64(screen.Height),
+	}
+
+	for {
+		introducer, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		switch introducer {
+		case 0x21:
+			// Extension: label, then sub-blocks
+			if err = skip(r, 1); err != nil {
+				return nil, err
+			}
+			if err = skipGIFSubBlocks(r); err != nil {
+				return nil, err
+			}
+
+		case 0x2C:
+			var image struct {
+				Left   uint16
+				Top    uint16
+				Width  uint16
+				Height uint16
+				Flags  uint8
+			}
+			if err = binary.Read(r, binary.LittleEndian, &image); err != nil {
+				return nil, err
+			}
+
+			// Local color table, then the LZW minimum code size, then the image data
+			if image.Flags&0x80 != 0 {
+				if err = skip(r, 3<<(image.Flags&0x07+1)); err != nil {
+					return nil, err
+				}
+			}
+			if err = skip(r, 1); err != nil {
+				return nil, err
+			}
+			if err = skipGIFSubBlocks(r); err != nil {
+				return nil, err
+			}
+
+			header.frames = append(header.frames, quickFrame{
+				width:      int64(image.Width),
+				height:     int64(image.Height),
+				x:          int64(image.Left),
+				y:          int64(image.Top),
+				depth:      8,
+				colorspace: "sRGB",
+			})
+			if len(header.frames) > maxQuickFrames {
+				return nil, errQuickUnsupported
+			}
+
+		case 0x3B:
+			// Trailer
+			return header, nil
+
+		default:
+			return nil, fmt.Errorf("invalid GIF block: %#x", introducer)
+		}
+	}
+}
+
+// uint24 decodes a little endian 24 bit integer
+func uint24(b []byte) int64 {
+	return int64(b[0]) | int64(b[1])<<8 | int64(b[2])<<16
+}
+
+// decodeWebPHeader reads the VP8, VP8L or VP8X chunk, and the ANMF chunks of animated images
+func decodeWebPHeader(r *bufio.Reader) (*quickHeader, error) {
+	if err := skip(r, 12); err != nil {
+		return nil, err
+	}
+
+	header := &quickHeader{}
+	for {
+		var chunk struct {
+			Type [4]byte
+			Size uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &chunk); err != nil {
+			if err == io.EOF && len(header.frames) > 0 {
+				return header, nil
+			}
+			return nil, err
+		}
+
+		// Chunks are padded to an even size
+		size := int64(chunk.Size) + int64(chunk.Size&1)
+
+		switch string(chunk.Type[:]) {
+		case "VP8X":
+			data := make([]byte, 10)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, err
+			}
+			header.pageWidth = uint24(data[4:7]) + 1
+			header.pageHeight = uint24(data[7:10]) + 1
+
+			// Not animated, so the canvas is the image
+			if data[0]&0x02 == 0 {
+				header.frames = []quickFrame{{
+					width:      header.pageWidth,
+					height:     header.pageHeight,
+					depth:      8,
+					colorspace: "sRGB",
+				}}
+				return header, nil
+			}
+
+			if err := skip(r, size-10); err != nil {
+				return nil, err
+			}
+
+		case "ANMF":
+			data := make([]byte, 16)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, err
+			}
+			header.frames = append(header.frames, quickFrame{
+				x:          uint24(data[0:3]) * 2,
+				y:          uint24(data[3:6]) * 2,
+				width:      uint24(data[6:9]) + 1,
+				height:     uint24(data[9:12]) + 1,
+				depth:      8,
+				colorspace: "sRGB",
+			})
+			if len(header.frames) > maxQuickFrames {
+				return nil, errQuickUnsupported
+			}
+
+			if err := skip(r, size-16); err != nil {
+				return nil, err
+			}
+
+		case "VP8 ":
+			// Frame tag, start code, then 14 bit width and height with 2 bit scaling
+			data := make([]byte, 10)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, err
+			}
+			if data[3] != 0x9D || data[4] != 0x01 || data[5] != 0x2A {
+				return nil, errors.New("invalid VP8 start code")
+			}
+			header.frames = []quickFrame{{
+				width:      int64(binary.LittleEndian.Uint16(data[6:8]) & 0x3FFF),
+				height:     int64(binary.LittleEndian.Uint16(data[8:10]) & 0x3FFF),
+				depth:      8,
+				colorspace: "sRGB",
+			}}
+			return header, nil
+
+		case "VP8L":
+			// Signature, then 14 bit width - 1 and height - 1
+			data := make([]byte, 5)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, err
+			}
+			if data[0] != 0x2F {
+				return nil, errors.New("invalid VP8L signature")
+			}
+			bits := binary.LittleEndian.Uint32(data[1:5])
+			header.frames = []quickFrame{{
+				width:      int64(bits&0x3FFF) + 1,
+				height:     int64(bits>>14&0x3FFF) + 1,
+				depth:      8,
+				colorspace: "sRGB",
+			}}
+			return header, nil
+
+		default:
+			if err := skip(r, size); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// decodeBMPHeader reads the BITMAPCOREHEADER or BITMAPINFOHEADER (and its later versions)
+func decodeBMPHeader(r *bufio.Reader) (*quickHeader, error) {
+	if err := skip(r, 14); err != nil {
+		return nil, err
+	}
+
+	var size uint32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return nil, err
+	}
+
+	frame := quickFrame{colorspace: "sRGB", depth: 8}
+	var bitCount uint16
+
+	if size == 12 {
+		var core struct {
+			Width    uint16
+			Height   uint16
+			Planes   uint16
+			BitCount uint16
+		}
+		if err := binary.Read(r, binary.LittleEndian, &core); err != nil {
+			return nil, err
+		}
+		frame.width, frame.height = int64(core.Width), int64(core.Height)
+		bitCount = core.BitCount
+	} else if size >= 40 {
+		var info struct {
+			Width    int32
+			Height   int32
+			Planes   uint16
+			BitCount uint16
+		}
+		if err := binary.Read(r, binary.LittleEndian, &info); err != nil {
+			return nil, err
+		}
+
+		// A negative height means the rows are stored top-down
+		frame.width, frame.height = int64(info.Width), int64(info.Height)
+		if frame.height < 0 {
+			frame.height = -frame.height
+		}
+		bitCount = info.BitCount
+	} else {
+		return nil, fmt.Errorf("invalid BMP header size: %v", size)
+	}
+
+	if bitCount == 16 {
+		frame.depth = 5
+	}
+
+	return &quickHeader{frames: []quickFrame{frame}}, nil
+}
+
+// decodeTIFFHeader walks the image file directories (IFDs), which may be anywhere in the file
+func decodeTIFFHeader(r io.ReaderAt, size int64) (*quickHeader, error) {
+	head := make([]byte, 8)
+	if _, err := r.ReadAt(head, 0); err != nil {
+		return nil, err
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if head[0] == 'M' {
+		order = binary.BigEndian
+	}
+
+	// BigTIFF has 64 bit offsets
+	if order.Uint16(head[2:4]) != 42 {
+		return nil, errQuickUnsupported
+	}
+
+	header := &quickHeader{}
+	seen := map[uint32]bool{}
+	offset := order.Uint32(head[4:8])
+
+	for offset != 0 {
+		if seen[offset] || int64(offset)+2 > size || len(header.frames) >= maxQuickFrames {
+			return nil, errors.New("invalid TIFF IFD offset")
+		}
+		seen[offset] = true
+
+		count := make([]byte, 2)
+		if _, err := r.ReadAt(count, int64(offset)); err != nil {
+			return nil, err
+		}
+
+		entries := make([]byte, int(order.Uint16(count))*12+4)
+		if _, err := r.ReadAt(entries, int64(offset)+2); err != nil {
+			return nil, err
+		}
+
+		frame := quickFrame{depth: 1, colorspace: "sRGB"}
+		for i := 0; i+12 <= len(entries)-4; i += 12 {
+			entry := entries[i : i+12]
+			tag := order.Uint16(entry[0:2])
+			fieldType := order.Uint16(entry[2:4])
+
+			// The first value of a SHORT or LONG field, which is stored in the entry
+			var value int64
+			switch fieldType {
+			case 3:
+				value = int64(order.Uint16(entry[8:10]))
+			case 4:
+				value = int64(order.Uint32(entry[8:12]))
+			default:
+				continue
+			}
+
+			switch tag {
+			case 256:
+				frame.width = value
+			case 257:
+				frame.height = value
+			case 258:
+				// One value per sample, which are stored elsewhere when they don't fit in the entry
+				frame.depth = value
+				if fieldType == 3 && order.Uint32(entry[4:8]) > 2 {
+					sample := make([]byte, 2)
+					if _, err := r.ReadAt(sample, int64(order.Uint32(entry[8:12]))); err != nil {
+						return nil, err
+					}
+					frame.depth = int64(order.Uint16(sample))
+				}
+			case 262:
+				switch value {
+				case 0, 1:
+					frame.colorspace = "Gray"
+				case 5:
+					frame.colorspace = "CMYK"
+				}
+			}
+		}
+
+		header.frames = append(header.frames, frame)
+		offset = order.Uint32(entries[len(entries)-4:])
+	}
+
+	return header, nil
+}
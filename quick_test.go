@@ -0,0 +1,183 @@
+package imagemagick_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kamermans/imagemagick"
+	test "github.com/kamermans/imagemagick/test_resources"
+)
+
+// quickTestImages returns the contents of small test images by file name
+func quickTestImages(t *testing.T) map[string][]byte {
+	images := map[string][]byte{}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 30, 20))); err != nil {
+		t.Fatalf("Unable to encode PNG: %v", err)
+	}
+	images["image.png"] = buf.Bytes()
+
+	buf = bytes.Buffer{}
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 31, 21)), nil); err != nil {
+		t.Fatalf("Unable to encode JPEG: %v", err)
+	}
+	images["image.jpg"] = buf.Bytes()
+
+	palette := color.Palette{color.Black, color.White}
+	buf = bytes.Buffer{}
+	if err := gif.EncodeAll(&buf, &gif.GIF{
+		Image: []*image.Paletted{
+			image.NewPaletted(image.Rect(0, 0, 40, 30), palette),
+			image.NewPaletted(image.Rect(5, 6, 15, 16), palette),
+		},
+		Delay: []int{10, 10},
+		Config: image.Config{
+			ColorModel: palette,
+			Width:      40,
+			Height:     30,
+		},
+	}); err != nil {
+		t.Fatalf("Unable to encode GIF: %v", err)
+	}
+	images["anim.gif"] = buf.Bytes()
+
+	// BITMAPFILEHEADER and BITMAPINFOHEADER of a top-down 24 bit image
+	buf = bytes.Buffer{}
+	buf.WriteString("BM")
+	binary.Write(&buf, binary.LittleEndian, []uint32{54, 0, 54, 40})
+	binary.Write(&buf, binary.LittleEndian, []int32{33, -23})
+	binary.Write(&buf, binary.LittleEndian, []uint16{1, 24})
+	buf.Write(make([]byte, 24))
+	images["image.bmp"] = buf.Bytes()
+
+	// Lossless WebP with a 34x24 image
+	bits := uint32(34-1) | uint32(24-1)<<14
+	buf = bytes.Buffer{}
+	buf.WriteString("RIFF\x1A\x00\x00\x00WEBPVP8L\x0D\x00\x00\x00\x2F")
+	binary.Write(&buf, binary.LittleEndian, bits)
+	buf.Write(make([]byte, 8))
+	images["image.webp"] = buf.Bytes()
+
+	// Big endian TIFF with two IFDs: 35x25 RGB and 10x5 gray
+	buf = bytes.Buffer{}
+	buf.WriteString("MM\x00\x2A")
+	binary.Write(&buf, binary.BigEndian, uint32(8))
+	writeIFD := func(width, height uint16, photometric uint16, next uint32) {
+		binary.Write(&buf, binary.BigEndian, uint16(3))
+		for _, entry := range [][2]uint16{{256, width}, {257, height}, {262, photometric}} {
+			binary.Write(&buf, binary.BigEndian, []uint16{entry[0], 3})
+			binary.Write(&buf, binary.BigEndian, uint32(1))
+			binary.Write(&buf, binary.BigEndian, []uint16{entry[1], 0})
+		}
+		binary.Write(&buf, binary.BigEndian, next)
+	}
+	writeIFD(35, 25, 2, 8+2+3*12+4)
+	writeIFD(10, 5, 1, 0)
+	images["pages.tif"] = buf.Bytes()
+
+	return images
+}
+
+func TestQuickDetails(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imagemagick")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, content := range quickTestImages(t) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatalf("Unable to write %v: %v", name, err)
+		}
+	}
+
+	mockExec := test.NewMockExec("TestHelperBatch")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+	parser.QuickDetails = true
+
+	expected := []struct {
+		file       string
+		format     string
+		geometry   string
+		page       string
+		colorspace string
+	}{
+		{"image.png", "PNG", "30x20+0+0", "30x20", "sRGB"},
+		{"image.jpg", "JPEG", "31x21+0+0", "31x21", "Gray"},
+		{"anim.gif", "GIF", "40x30+0+0", "40x30", "sRGB"},
+		{"anim.gif", "GIF", "10x10+5+6", "40x30", "sRGB"},
+		{"image.bmp", "BMP", "33x23+0+0", "33x23", "sRGB"},
+		{"image.webp", "WEBP", "34x24+0+0", "34x24", "sRGB"},
+		{"pages.tif", "TIFF", "35x25+0+0", "35x25", "sRGB"},
+		{"pages.tif", "TIFF", "10x5+0+0", "10x5", "Gray"},
+	}
+
+	files := []string{}
+	for _, e := range expected {
+		if len(files) == 0 || files[len(files)-1] != filepath.Join(dir, e.file) {
+			files = append(files, filepath.Join(dir, e.file))
+		}
+	}
+
+	results, parserErr := parser.GetImageDetails(files...)
+	if parserErr != nil {
+		t.Fatalf("GetImageDetails() failed: %v", parserErr.Error())
+	}
+
+	if mockExec.RunCount() != 0 {
+		t.Fatalf("QuickDetails failed: expected no convert runs, got %v", mockExec.RunCount())
+	}
+
+	if len(results) != len(expected) {
+		t.Fatalf("QuickDetails failed: expected %v results, got %v", len(expected), len(results))
+	}
+
+	for i, e := range expected {
+		details := results[i].Image
+		geometry := details.Geometry.Spec().String()
+		page := details.PageGeometry.Dimensions
+		if results[i].File != filepath.Join(dir, e.file) || details.Format != e.format || geometry != e.geometry ||
+			*page != mustDimensions(t, e.page) || details.Colorspace != e.colorspace {
+			t.Fatalf("QuickDetails failed for %v: expected %+v, got %v %v %v %v", results[i].File, e, details.Format, geometry, page, details.Colorspace)
+		}
+	}
+
+	if results[3].Frame != 1 || results[3].Image.Scenes != 2 {
+		t.Fatalf("QuickDetails failed: expected frame 1 of 2, got %v of %v", results[3].Frame, results[3].Image.Scenes)
+	}
+
+	// Unsupported formats fall back to convert
+	svg := filepath.Join(dir, "image.svg")
+	if err := ioutil.WriteFile(svg, []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`), 0644); err != nil {
+		t.Fatalf("Unable to write %v: %v", svg, err)
+	}
+
+	results, parserErr = parser.GetImageDetails(files[0], svg)
+	if parserErr != nil {
+		t.Fatalf("GetImageDetails() failed: %v", parserErr.Error())
+	}
+
+	if mockExec.RunCount() != 1 || len(mockExec.LastRun().Args()) != 2 || len(results) != 2 || results[1].File != svg {
+		t.Fatalf("QuickDetails failed: expected a convert run for the SVG only")
+	}
+}
+
+func mustDimensions(t *testing.T, geometry string) imagemagick.Dimensions {
+	spec, err := imagemagick.ParseGeometry(geometry)
+	if err != nil {
+		t.Fatalf("ParseGeometry(%q) failed: %v", geometry, err)
+	}
+	return imagemagick.Dimensions{Width: int64(spec.Width), Height: int64(spec.Height)}
+}
@@ -0,0 +1,145 @@
+package imagemagick
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var formatHintPattern = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+
+// isScriptCoder returns true if ImageMagick would run a command, read another file or fetch a URL
+// with the coder instead of decoding image data
+func isScriptCoder(coder string) bool {
+	for _, script := range scriptCoders {
+		if strings.EqualFold(script, coder) {
+			return true
+		}
+	}
+	return false
+}
+
+// stdinArg returns the `convert` argument to read an image from stdin, like "-" or "JPEG:-"
+func stdinArg(formatHint string) (arg string, err *ParserError) {
+	if formatHint == "" {
+		return "-", nil
+	}
+
+	if !formatHintPattern.MatchString(formatHint) {
+		err = NewParserError("Invalid format hint: "+formatHint, "-", "", []byte{}, []byte{})
+		return
+	}
+
+	return strings.ToUpper(formatHint) + ":-", nil
+}
+
+// GetImageDetailsFromReader computes ImageDetails for an image read from r, which is piped into
+// `convert` on stdin, so there is no need to write it to a temp file first.  ImageMagick detects
+// the format from the image data, but some formats (like TGA or raw pixel formats) can't be
+// detected, so formatHint may be set to the ImageMagick format name, like "TGA" or "JPEG".
+// Leave formatHint empty to let ImageMagick decide (it is required when Parser.AllowedInputCoders
+// is set).  With Parser.SafeFilenames, the coders that don't decode image data (like MSL, MVG,
+// TEXT or EPHEMERAL) are rejected, and without a formatHint the format is sniffed from the data
+// and forced.  Note that the image Name and the result File will be "-" (or "FORMAT:-" with a
+// formatHint or a sniffed format).
+func (parser *Parser) GetImageDetailsFromReader(r io.Reader, formatHint string) (results []*ImageResult, err *ParserError) {
+	return parser.GetImageDetailsFromReaderContext(context.Background(), r, formatHint)
+}
+
+// GetImageDetailsFromReaderContext is like GetImageDetailsFromReader, but the `convert` process
+// is killed if ctx is canceled or its deadline expires before it exits
+func (parser *Parser) GetImageDetailsFromReaderContext(ctx context.Context, r io.Reader, formatHint string) (results []*ImageResult, err *ParserError) {
+	input, err := stdinArg(formatHint)
+	if err != nil {
+		return
+	}
+
+	// The format can't be checked without a hint
+	if len(parser.AllowedInputCoders) > 0 && !parser.coderAllowed(formatHint) {
+		err = inputError(input, fmt.Errorf("%w: %q format is not allowed from a reader", ErrCoderNotAllowed, formatHint))
+		return
+	}
+
+	if parser.SafeFilenames {
+		if formatHint == "" {
+			// ImageMagick would pick the coder from the data, which may be MVG or MSL, so it's
+			// sniffed and forced like the coder of a file with an unknown extension
+			buffered := bufio.NewReaderSize(r, sniffLength)
+			header, _ := buffered.Peek(sniffLength)
+			coder, _ := sniffHeader(header)
+			if coder == "" {
+				err = inputError(input, fmt.Errorf("%w: the format of the data can't be determined", ErrUnsafeFilename))
+				return
+			}
+			input, r = coder+":-", buffered
+		} else if isScriptCoder(formatHint) {
+			err = inputError(input, fmt.Errorf("%w: %q format is not safe to read", ErrCoderNotAllowed, formatHint))
+			return
+		}
+	}
+
+	args := []string{input, "json:-"}
+
+	var stdout, stderr bytes.Buffer
+	if cmdErr := parser.run(ctx, r, &stdout, &stderr, args...); cmdErr != nil {
+		err = parser.newCommandError(cmdErr, input, args, stdout.Bytes(), stderr.Bytes())
+		return
+	}
+
+	jsonBlob := stdout.Bytes()
+
+	results, jsonErr := parser.GetImageDetailsFromJSON(&jsonBlob)
+	if jsonErr != nil {
+		err = NewParserError(jsonErr.Error(), input, "", []byte{}, []byte{})
+		return
+	}
+
+	groupResults([]string{input}, []string{input}, results)
+
+	if err = parser.checkWarnings([]string{input}, args, results, stderr.Bytes()); err != nil {
+		results = nil
+	}
+
+	return
+}
+
+// GetImageDetailsFromBytes computes ImageDetails for an image in memory.  See
+// GetImageDetailsFromReader for the meaning of formatHint.
+func (parser *Parser) GetImageDetailsFromBytes(data []byte, formatHint string) (results []*ImageResult, err *ParserError) {
+	return parser.GetImageDetailsFromReaderContext(context.Background(), bytes.NewReader(data), formatHint)
+}
+
+// ConvertStream is a helper to call the ImageMagick `convert` command with stdin read from r and
+// stdout written to w, which allows images to be converted without temp files by using "-" (or
+// "FORMAT:-") as the input and output files, for example:
+//
+//	parser.ConvertStream(upload, response, "-", "-resize", "50%", "PNG:-")
+//
+// It will return the stdErr and a ParserError if the command failed.
+func (parser *Parser) ConvertStream(r io.Reader, w io.Writer, args ...string) (stdErr *[]byte, err *ParserError) {
+	return parser.ConvertStreamContext(context.Background(), r, w, args...)
+}
+
+// ConvertStreamContext is like ConvertStream, but the `convert` process is killed if ctx is
+// canceled or its deadline expires before it exits
+func (parser *Parser) ConvertStreamContext(ctx context.Context, r io.Reader, w io.Writer, args ...string) (stdErr *[]byte, err *ParserError) {
+
+	var stderr bytes.Buffer
+	if cmdErr := parser.run(ctx, r, w, &stderr, args...); cmdErr != nil {
+		err = parser.newCommandError(
+			cmdErr,
+			"",
+			args,
+			[]byte{},
+			stderr.Bytes(),
+		)
+	}
+
+	stdErrBytes := stderr.Bytes()
+
+	return &stdErrBytes, err
+}
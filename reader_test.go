@@ -0,0 +1,177 @@
+package imagemagick_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/kamermans/imagemagick"
+	test "github.com/kamermans/imagemagick/test_resources"
+)
+
+const fakeImageData = "GIF89a fake image data"
+
+func TestGetImageDetailsFromReader(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperReadStdin")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+
+	results, err := parser.GetImageDetailsFromReader(strings.NewReader(fakeImageData), "gif")
+	if err != nil {
+		t.Fatalf("GetImageDetailsFromReader() failed: %v", err.Error())
+	}
+
+	if len(results) != 1 {
+		t.Fatalf("GetImageDetailsFromReader() failed: expected 1 result, got %v", len(results))
+	}
+
+	args := mockExec.LastRun().Args()
+	if len(args) != 2 || args[0] != "GIF:-" || args[1] != "json:-" {
+		t.Fatalf("GetImageDetailsFromReader() failed: unexpected args %v", args)
+	}
+}
+
+func TestGetImageDetailsFromBytes(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperReadStdin")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+
+	results, err := parser.GetImageDetailsFromBytes([]byte(fakeImageData), "")
+	if err != nil {
+		t.Fatalf("GetImageDetailsFromBytes() failed: %v", err.Error())
+	}
+
+	if len(results) != 1 {
+		t.Fatalf("GetImageDetailsFromBytes() failed: expected 1 result, got %v", len(results))
+	}
+
+	args := mockExec.LastRun().Args()
+	if len(args) != 2 || args[0] != "-" || args[1] != "json:-" {
+		t.Fatalf("GetImageDetailsFromBytes() failed: unexpected args %v", args)
+	}
+
+	// The helper fails if stdin doesn't match
+	_, err = parser.GetImageDetailsFromBytes([]byte("something else"), "")
+	if err == nil {
+		t.Fatalf("GetImageDetailsFromBytes() did not fail as expected")
+	}
+}
+
+func TestGetImageDetailsFromReaderInvalidHint(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperReadStdin")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+
+	_, err := parser.GetImageDetailsFromReader(strings.NewReader(fakeImageData), "msl:foo")
+	if err == nil {
+		t.Fatalf("GetImageDetailsFromReader() did not fail as expected")
+	}
+
+	if mockExec.RunCount() != 0 {
+		t.Fatalf("GetImageDetailsFromReader() failed: command should not be run")
+	}
+}
+
+func TestGetImageDetailsFromReaderSafeFilenames(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperSafeFilenames")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+	parser.SafeFilenames = true
+
+	for _, hint := range []string{"MSL", "ephemeral", "Text", "mvg"} {
+		_, err := parser.GetImageDetailsFromReader(strings.NewReader(fakeImageData), hint)
+		if err == nil || !errors.Is(err, imagemagick.ErrCoderNotAllowed) {
+			t.Fatalf("GetImageDetailsFromReader(%q) failed: expected ErrCoderNotAllowed, got %v", hint, err)
+		}
+	}
+
+	// Without a hint, ImageMagick would detect the MVG script itself
+	_, err := parser.GetImageDetailsFromReader(strings.NewReader("viewbox 0 0 1 1\nimage over 0,0 0,0 'msl:/tmp/x'"), "")
+	if err == nil || !errors.Is(err, imagemagick.ErrUnsafeFilename) {
+		t.Fatalf("GetImageDetailsFromReader() failed: expected ErrUnsafeFilename, got %v", err)
+	}
+
+	if mockExec.RunCount() != 0 {
+		t.Fatalf("GetImageDetailsFromReader() failed: command should not be run")
+	}
+
+	for hint, expected := range map[string]string{"": "GIF:-", "gif": "GIF:-", "tga": "TGA:-"} {
+		_, err = parser.GetImageDetailsFromReader(strings.NewReader(fakeImageData), hint)
+		if err == nil || string(err.StdErr()) != expected+"\njson:-" {
+			t.Fatalf("GetImageDetailsFromReader(%q) failed: expected %v to be read, got %v", hint, expected, err)
+		}
+	}
+}
+
+func TestHelperReadStdin(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	stdin, _ := ioutil.ReadAll(os.Stdin)
+	if string(stdin) != fakeImageData {
+		fmt.Fprintf(os.Stderr, "unexpected stdin: %q", stdin)
+		os.Exit(2)
+	}
+
+	file := "test_resources/json_output/image_metadata_linux.json"
+	jsonBlob, readErr := ioutil.ReadFile(file)
+	if readErr != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", readErr.Error())
+		os.Exit(2)
+	}
+
+	os.Stdout.Write(jsonBlob)
+}
+
+func TestConvertStream(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperConvertStream")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+
+	var out bytes.Buffer
+	expectedArgs := []string{"-", "-resize", "50%", "PNG:-"}
+	stdErr, err := parser.ConvertStream(strings.NewReader(fakeImageData), &out, expectedArgs...)
+	if err != nil {
+		t.Fatalf("ConvertStream() failed: %v", err.Error())
+	}
+
+	if len(*stdErr) != 0 {
+		t.Fatalf("ConvertStream() failed: expected StdErr to be empty, got: %v", string(*stdErr))
+	}
+
+	if out.String() != strings.ToUpper(fakeImageData) {
+		t.Fatalf("ConvertStream() failed: unexpected output %q", out.String())
+	}
+
+	actualArgs := mockExec.LastRun().Args()
+	if len(expectedArgs) != len(actualArgs) {
+		t.Fatalf("ConvertStream() failed: expected %v, got %v", expectedArgs, actualArgs)
+	}
+
+	for i := range expectedArgs {
+		if expectedArgs[i] != actualArgs[i] {
+			t.Fatalf("ConvertStream() failed: expected %v, got %v", expectedArgs, actualArgs)
+		}
+	}
+}
+
+func TestHelperConvertStream(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	stdin, _ := ioutil.ReadAll(os.Stdin)
+	os.Stdout.Write(bytes.ToUpper(stdin))
+}
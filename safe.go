@@ -0,0 +1,215 @@
+package imagemagick
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// Errors for the input files that are rejected when Parser.SafeFilenames or
+// Parser.AllowedInputCoders is set.  The ParserError matches them with errors.Is().
+var (
+	ErrUnsafeFilename  = errors.New("imagemagick: unsafe file name")
+	ErrCoderNotAllowed = errors.New("imagemagick: input coder not allowed")
+)
+
+// The ImageMagick coders for common image file extensions
+var extensionCoders = map[string]string{
+	".avif": "AVIF",
+	".bmp":  "BMP",
+	".dng":  "DNG",
+	".eps":  "EPS",
+	".gif":  "GIF",
+	".heic": "HEIC",
+	".heif": "HEIC",
+	".ico":  "ICO",
+	".jfif": "JPEG",
+	".jp2":  "JP2",
+	".jpe":  "JPEG",
+	".jpeg": "JPEG",
+	".jpg":  "JPEG",
+	".jxl":  "JXL",
+	".pbm":  "PBM",
+	".pdf":  "PDF",
+	".pgm":  "PGM",
+	".png":  "PNG",
+	".pnm":  "PNM",
+	".ppm":  "PPM",
+	".ps":   "PS",
+	".psd":  "PSD",
+	".svg":  "SVG",
+	".tga":  "TGA",
+	".tif":  "TIFF",
+	".tiff": "TIFF",
+	".webp": "WEBP",
+}
+
+// extensionCoder returns the ImageMagick coder for the file extension, or "" if it is unknown
+func extensionCoder(file string) string {
+	return extensionCoders[strings.ToLower(filepath.Ext(file))]
+}
+
+// safeInputs returns true if the input file names need to be made safe before they are passed to
+// `convert`
+func (parser *Parser) safeInputs() bool {
+	return parser.SafeFilenames || len(parser.AllowedInputCoders) > 0
+}
+
+// checkInputs returns true if the input files need to be checked before they are passed to `convert`
+func (parser *Parser) checkInputs() bool {
+	return parser.safeInputs() || parser.SniffInputs
+}
+
+// checkInput returns the `convert` input argument for a file, or an error if the file is rejected
+// by the safe file name checks or by sniffing its format
+func (parser *Parser) checkInput(file string) (input string, err error) {
+	if parser.safeInputs() {
+		return parser.safeInput(file)
+	}
+
+	if parser.SniffInputs {
+		if _, err = parser.sniffFile(parser.readName(file)); err != nil {
+			return "", err
+		}
+	}
+
+	return file, nil
+}
+
+// readName returns the name of the file that `convert` reads for an input argument, without the
+// coder prefix and frame selection, like "photo.jpg" for "JPEG:photo.jpg[0]".  A file that exists
+// with the literal name is read as it is.
+func (parser *Parser) readName(file string) string {
+	if _, err := os.Stat(parser.localPath(file)); err == nil {
+		return file
+	}
+	return normalizeImageName(file)
+}
+
+// coderAllowed returns true if the coder is in Parser.AllowedInputCoders
+func (parser *Parser) coderAllowed(coder string) bool {
+	for _, allowed := range parser.AllowedInputCoders {
+		if strings.EqualFold(allowed, coder) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches a coder or protocol prefix like "msl:", "https:" or "x:" (the X11 coder)
+var unsafePrefixPattern = regexp.MustCompile(`^[A-Za-z0-9]+:`)
+
+// hasCoderPrefix returns true if ImageMagick would read the start of the file name as a coder or
+// protocol.  A single letter is a coder too (like "x:" or the "R:" channel coder), except on
+// Windows, where it is a drive letter.
+func hasCoderPrefix(file string) bool {
+	prefix := unsafePrefixPattern.FindString(file)
+	if prefix == "" {
+		return false
+	}
+	if len(prefix) == 2 && runtime.GOOS == "windows" {
+		c := prefix[0] | 0x20
+		return c < 'a' || c > 'z'
+	}
+	return true
+}
+
+// safeInput returns the form of the file name that ImageMagick can only read as a local file, with
+// an explicit coder prefix.  Names that ImageMagick would read as something else, like
+// "@list.txt", "|command" or "https://host/image.png", are rejected.  The coder is sniffed from the
+// file contents with Parser.SniffInputs or when the extension is unknown, otherwise the extension
+// is used.  Files whose coder can't be determined are rejected.
+func (parser *Parser) safeInput(file string) (input string, err error) {
+	switch {
+	case file == "":
+		return "", fmt.Errorf("%w: the name is empty", ErrUnsafeFilename)
+	case strings.HasPrefix(file, "@"):
+		return "", fmt.Errorf("%w: %q would be read as a list of files", ErrUnsafeFilename, file)
+	case strings.HasPrefix(file, "|"):
+		return "", fmt.Errorf("%w: %q would be run as a command", ErrUnsafeFilename, file)
+	case hasCoderPrefix(file):
+		return "", fmt.Errorf("%w: %q has a coder or protocol prefix", ErrUnsafeFilename, file)
+	}
+
+	// Keep names like "-resize" and "-" from being read as an option or stdin
+	input = file
+	if strings.HasPrefix(input, "-") {
+		input = "./" + input
+	}
+
+	// Force the coder so ImageMagick can't be tricked into using another one by the file contents.
+	// Without a known extension, the coder is sniffed, since ImageMagick would otherwise pick it from
+	// the contents, which may be MVG or MSL.
+	coder := extensionCoder(file)
+	if coder == "" && len(parser.AllowedInputCoders) > 0 && !parser.SniffInputs {
+		return "", fmt.Errorf("%w: %q has unknown format", ErrCoderNotAllowed, file)
+	}
+	if parser.SniffInputs || coder == "" {
+		if coder, err = parser.sniffFile(file); err != nil {
+			if errors.Is(err, ErrUnknownFormat) && !parser.SniffInputs {
+				return "", fmt.Errorf("%w: the format of %q can't be determined", ErrUnsafeFilename, file)
+			}
+			return "", err
+		}
+	}
+
+	if len(parser.AllowedInputCoders) > 0 && !parser.coderAllowed(coder) {
+		return "", fmt.Errorf("%w: %q has %v format", ErrCoderNotAllowed, file, coder)
+	}
+
+	return coder + ":" + input, nil
+}
+
+// inputError creates a ParserError for a file that was rejected by checkInput
+func inputError(file string, err error) *ParserError {
+	parserErr := NewParserError(err.Error(), file, "", []byte{}, []byte{})
+	parserErr.cause = err
+	return parserErr
+}
+
+// inputArgs returns the `convert` read settings and input arguments for the given files.  The files
+// are passed as they are (after sniffing their format with Parser.SniffInputs) when opts is nil and
+// the safe file name checks are disabled.  Otherwise the names are taken literally, with the frame
+// selection from opts.
+func (parser *Parser) inputArgs(files []string, opts *IdentifyOptions) (settings []string, inputs []string, err *ParserError) {
+	if opts == nil && !parser.safeInputs() {
+		for _, file := range files {
+			if _, checkErr := parser.checkInput(file); checkErr != nil {
+				return nil, nil, inputError(file, checkErr)
+			}
+		}
+		return nil, files, nil
+	}
+
+	if opts == nil {
+		opts = &IdentifyOptions{}
+	}
+
+	if opts.Density < 0 {
+		return nil, nil, NewParserError(fmt.Sprintf("Invalid density: %v", opts.Density), strings.Join(files, ", "), "", []byte{}, []byte{})
+	}
+
+	selection, selectionErr := opts.frameSelection()
+	if selectionErr != nil {
+		return nil, nil, NewParserError(selectionErr.Error(), strings.Join(files, ", "), "", []byte{}, []byte{})
+	}
+
+	if opts.Density > 0 {
+		settings = append(settings, "-density", formatFloat(opts.Density))
+	}
+
+	inputs = make([]string, 0, len(files))
+	for _, file := range files {
+		input, checkErr := parser.checkInput(file)
+		if checkErr != nil {
+			return nil, nil, inputError(file, checkErr)
+		}
+		inputs = append(inputs, frameInput(input, selection))
+	}
+
+	return settings, inputs, nil
+}
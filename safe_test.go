@@ -0,0 +1,123 @@
+package imagemagick_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/kamermans/imagemagick"
+	test "github.com/kamermans/imagemagick/test_resources"
+)
+
+// TestHelperSafeFilenames mocks `convert` and prints the arguments it was given
+func TestHelperSafeFilenames(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	fmt.Fprint(os.Stderr, strings.Join(helperArgs(), "\n"))
+	os.Exit(1)
+}
+
+func TestSafeFilenames(t *testing.T) {
+	dir, tempErr := ioutil.TempDir("", "imagemagick-test")
+	if tempErr != nil {
+		t.Fatal(tempErr)
+	}
+	defer os.RemoveAll(dir)
+
+	// Files without a known extension get the coder from their contents
+	writeFile := func(name string, data string) string {
+		file := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(file, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	scan := writeFile("a[99999]", "\x89PNG\r\n\x1A\n")
+	noext := writeFile("noext", "GIF89a")
+	mvg := writeFile("evil.svgz.txt", "push graphic-context\nviewbox 0 0 640 480\nimage over 0,0 0,0 'ephemeral:/etc/passwd'\n")
+
+	mockExec := test.NewMockExec("TestHelperSafeFilenames")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+	parser.SafeFilenames = true
+
+	unsafe := []string{"", "msl:foo", "ephemeral:x", "@list.txt", "https://example.com/x.png", "|ls", "JPEG:x.jpg", mvg}
+	if runtime.GOOS != "windows" {
+		// The X11 and channel coders, which are drive letters on Windows
+		unsafe = append(unsafe, "x:root", "R:image.png", `C:\images\x.png`)
+	}
+	for _, file := range unsafe {
+		_, err := parser.GetImageDetails(file)
+		if err == nil || !errors.Is(err, imagemagick.ErrUnsafeFilename) || err.File() != file {
+			t.Fatalf("GetImageDetails(%q) did not fail as expected: %v", file, err)
+		}
+	}
+
+	// Without a known extension, a file that can't be sniffed is rejected too
+	if _, err := parser.GetImageDetails(filepath.Join(dir, "missing")); err == nil || !errors.Is(err, imagemagick.ErrUnableToOpen) {
+		t.Fatalf("GetImageDetails() did not fail as expected: %v", err)
+	}
+
+	if mockExec.RunCount() != 0 {
+		t.Fatalf("SafeFilenames failed: convert should not run for unsafe files")
+	}
+
+	files := []string{"-resize.png", scan, "/foo/photo.JPG", noext}
+	expected := []string{"PNG:./-resize.png", "PNG:" + scan + "[0-2147483647]", "JPEG:/foo/photo.JPG", "GIF:" + noext}
+	if runtime.GOOS == "windows" {
+		files = append(files, `C:\images\x.png`)
+		expected = append(expected, `PNG:C:\images\x.png`)
+	}
+	expected = append(expected, "json:-")
+
+	_, err := parser.GetImageDetails(files...)
+	if err == nil {
+		t.Fatalf("GetImageDetails() failed: the mock should fail")
+	}
+
+	if string(err.StdErr()) != strings.Join(expected, "\n") {
+		t.Fatalf("SafeFilenames failed: expected args %q, got %q", expected, err.StdErr())
+	}
+}
+
+func TestAllowedInputCoders(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperBatch")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+	parser.Workers = 1
+	parser.BatchSize = 4
+	parser.AllowedInputCoders = []string{"png", "GIF"}
+
+	files := []string{"/foo/a.png", "/foo/b.svg", "/foo/c.gif", "/foo/noext"}
+	results, errs := runBatchRetry(t, parser, files)
+
+	if len(results) != 2 || len(errs) != 2 {
+		t.Fatalf("AllowedInputCoders failed: expected 2 results and 2 errors, got %v and %v", len(results), len(errs))
+	}
+
+	for _, err := range errs {
+		if !errors.Is(err, imagemagick.ErrCoderNotAllowed) {
+			t.Fatalf("AllowedInputCoders failed: expected ErrCoderNotAllowed, got %v", err)
+		}
+	}
+
+	for _, result := range results {
+		if !strings.HasPrefix(result.Image.Name, strings.ToUpper(result.File[len(result.File)-3:])+":") {
+			t.Fatalf("AllowedInputCoders failed: expected a coder prefix, got %v", result.Image.Name)
+		}
+	}
+
+	if mockExec.RunCount() != 1 {
+		t.Fatalf("AllowedInputCoders failed: expected 1 run, got %v", mockExec.RunCount())
+	}
+}
@@ -0,0 +1,139 @@
+package imagemagick
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The multipliers of the size prefixes, which are powers of 1000 (like "MB") or, with an "i",
+// powers of 1024 (like "MiB")
+var sizePrefixes = map[byte]int{
+	'K': 1,
+	'M': 2,
+	'G': 3,
+	'T': 4,
+	'P': 5,
+	'E': 6,
+}
+
+// ParseSize parses a size as ImageMagick formats it, like "1200B", "523KB", "1.2MB", "2.5GiB" or
+// "15.9MP" (megapixels).  The unit ("B" for bytes or "P" for pixels) is optional, and prefixes
+// are powers of 1000, or powers of 1024 with an "i" like "KiB".
+func ParseSize(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+
+	// Split the number from the suffix
+	end := len(s)
+	for end > 0 && strings.IndexByte("0123456789.", s[end-1]) < 0 {
+		end--
+	}
+	number, suffix := s[:end], s[end:]
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || math.Signbit(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("Invalid size: %q", s)
+	}
+
+	multiplier, ok := sizeMultiplier(suffix)
+	if !ok {
+		return 0, fmt.Errorf("Invalid size unit: %q", s)
+	}
+	return value * multiplier, nil
+}
+
+// sizeMultiplier returns the multiplier of a size suffix, like 1000 for "KB" or 1048576 for "MiB"
+func sizeMultiplier(suffix string) (float64, bool) {
+	// A single "P" is the pixel unit rather than the peta prefix
+	switch suffix {
+	case "", "B", "P":
+		return 1, true
+	}
+
+	prefix := strings.ToUpper(suffix[:1])[0]
+	exponent, ok := sizePrefixes[prefix]
+	if !ok {
+		return 0, false
+	}
+
+	rest := suffix[1:]
+	base := 1000.0
+	if strings.HasPrefix(rest, "i") {
+		base = 1024
+		rest = rest[1:]
+	}
+
+	switch rest {
+	case "", "B", "P":
+		return math.Pow(base, float64(exponent)), true
+	}
+	return 0, false
+}
+
+// ParseElapsedTime parses an ImageMagick elapsed time, like "0:01.049" (minutes and seconds) or
+// "1:02:03.5" (hours, minutes and seconds)
+func ParseElapsedTime(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("Invalid elapsed time: %q", s)
+	}
+
+	var seconds float64
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil || math.Signbit(value) || math.IsInf(value, 0) || (i > 0 && value >= 60) {
+			return 0, fmt.Errorf("Invalid elapsed time: %q", s)
+		}
+		// Only the seconds can have a fraction
+		if i < len(parts)-1 && value != math.Trunc(value) {
+			return 0, fmt.Errorf("Invalid elapsed time: %q", s)
+		}
+		seconds = seconds*60 + value
+	}
+
+	return secondsDuration(seconds), nil
+}
+
+// ParseUserTime parses an ImageMagick user CPU time, like "0.030u"
+func ParseUserTime(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	seconds, err := strconv.ParseFloat(strings.TrimSuffix(s, "u"), 64)
+	if err != nil || math.Signbit(seconds) || math.IsInf(seconds, 0) {
+		return 0, fmt.Errorf("Invalid user time: %q", s)
+	}
+	return secondsDuration(seconds), nil
+}
+
+// secondsDuration converts seconds to a Duration, rounded to the nearest microsecond since the
+// times are printed with at most 6 digits
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(math.Round(seconds*1e6)) * time.Microsecond
+}
+
+// FilesizeBytes returns the Filesize in bytes, or an error if ImageMagick reported it in a format
+// that can't be parsed
+func (details ImageDetails) FilesizeBytes() (int64, error) {
+	size, err := ParseSize(details.Filesize)
+	if err != nil {
+		return 0, err
+	}
+	return int64(math.Round(size)), nil
+}
+
+// PixelRate returns the PixelsPerSecond, like 15900000 for "15.9MP"
+func (details ImageDetails) PixelRate() (float64, error) {
+	return ParseSize(details.PixelsPerSecond)
+}
+
+// ElapsedDuration returns the ElapsedTime, the wall clock time ImageMagick took to read the image
+func (details ImageDetails) ElapsedDuration() (time.Duration, error) {
+	return ParseElapsedTime(details.ElapsedTime)
+}
+
+// UserDuration returns the UserTime, the CPU time ImageMagick took to read the image
+func (details ImageDetails) UserDuration() (time.Duration, error) {
+	return ParseUserTime(details.UserTime)
+}
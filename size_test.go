@@ -0,0 +1,106 @@
+package imagemagick_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kamermans/imagemagick"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]float64{
+		"1200B":    1200,
+		"1200":     1200,
+		"0B":       0,
+		"523KB":    523000,
+		"523kB":    523000,
+		"1.2MB":    1200000,
+		"2.5GiB":   2.5 * 1024 * 1024 * 1024,
+		"1KiB":     1024,
+		"3TB":      3e12,
+		"2PB":      2e15,
+		"4235000B": 4235000,
+		"15.9MP":   15900000,
+		"800P":     800,
+		"1.5GP":    1.5e9,
+		" 12MiB ":  12 * 1024 * 1024,
+	}
+
+	for s, expected := range tests {
+		size, err := imagemagick.ParseSize(s)
+		if err != nil {
+			t.Fatalf("ParseSize(%q) failed: %v", s, err)
+		}
+		if size != expected {
+			t.Fatalf("ParseSize(%q) failed: expected %v, got %v", s, expected, size)
+		}
+	}
+
+	for _, s := range []string{"", "B", "MB", "-1B", "1.2.3MB", "12XB", "12MX", "12MiX", "12Bytes", "12 MB"} {
+		if size, err := imagemagick.ParseSize(s); err == nil {
+			t.Fatalf("ParseSize(%q) should fail, got %v", s, size)
+		}
+	}
+}
+
+func TestParseTimes(t *testing.T) {
+	elapsed := map[string]time.Duration{
+		"0:01.049":  1049 * time.Millisecond,
+		"2:03.5":    2*time.Minute + 3500*time.Millisecond,
+		"1:02:03.5": time.Hour + 2*time.Minute + 3500*time.Millisecond,
+		"75:00.000": 75 * time.Minute,
+		"0.25":      250 * time.Millisecond,
+	}
+	for s, expected := range elapsed {
+		if duration, err := imagemagick.ParseElapsedTime(s); err != nil || duration != expected {
+			t.Fatalf("ParseElapsedTime(%q) failed: expected %v, got %v, %v", s, expected, duration, err)
+		}
+	}
+	for _, s := range []string{"", "0:60.000", "1.5:00.000", "a:01.049", "1:2:3:4", "-0:01.000"} {
+		if duration, err := imagemagick.ParseElapsedTime(s); err == nil {
+			t.Fatalf("ParseElapsedTime(%q) should fail, got %v", s, duration)
+		}
+	}
+
+	if duration, err := imagemagick.ParseUserTime("0.030u"); err != nil || duration != 30*time.Millisecond {
+		t.Fatalf("ParseUserTime() failed: %v, %v", duration, err)
+	}
+	for _, s := range []string{"", "u", "0.03x", "-1u"} {
+		if duration, err := imagemagick.ParseUserTime(s); err == nil {
+			t.Fatalf("ParseUserTime(%q) should fail, got %v", s, duration)
+		}
+	}
+}
+
+func TestImageDetailsSizes(t *testing.T) {
+	details := imagemagick.ImageDetails{
+		Filesize:        "1.2MB",
+		PixelsPerSecond: "15.9MP",
+		ElapsedTime:     "0:01.049",
+		UserTime:        "0.030u",
+	}
+
+	if size := details.Size(); size != 1200000 {
+		t.Fatalf("Size() failed: expected 1200000, got %v", size)
+	}
+	if size, err := details.FilesizeBytes(); err != nil || size != 1200000 {
+		t.Fatalf("FilesizeBytes() failed: %v, %v", size, err)
+	}
+	if rate, err := details.PixelRate(); err != nil || rate != 15900000 {
+		t.Fatalf("PixelRate() failed: %v, %v", rate, err)
+	}
+	if elapsed, err := details.ElapsedDuration(); err != nil || elapsed != 1049*time.Millisecond {
+		t.Fatalf("ElapsedDuration() failed: %v, %v", elapsed, err)
+	}
+	if user, err := details.UserDuration(); err != nil || user != 30*time.Millisecond {
+		t.Fatalf("UserDuration() failed: %v, %v", user, err)
+	}
+
+	details.Filesize = "lots"
+	if size := details.Size(); size != 0 {
+		t.Fatalf("Size() failed: expected 0 for an invalid Filesize, got %v", size)
+	}
+	if _, err := details.FilesizeBytes(); err == nil {
+		t.Fatalf("FilesizeBytes() failed: expected an error for an invalid Filesize")
+	}
+}
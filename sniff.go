@@ -0,0 +1,201 @@
+package imagemagick
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrUnknownFormat is returned by SniffFormat when the data is not in a recognized image format.
+// With Parser.SniffInputs, the ParserError of a rejected file matches it with errors.Is().
+var ErrUnknownFormat = errors.New("imagemagick: unknown image format")
+
+// sniffLength is the number of bytes SniffFormat reads, which is enough to find the root element
+// of most SVG files
+const sniffLength = 1024
+
+// A magic number at the start of the data and the format it identifies
+type magicFormat struct {
+	magic    []byte
+	coder    string
+	mimeType string
+}
+
+// The formats that can be identified by the first bytes of the data, checked in order
+var magicFormats = []magicFormat{
+	{[]byte("\xFF\xD8\xFF"), "JPEG", "image/jpeg"},
+	{[]byte("\x89PNG\r\n\x1A\n"), "PNG", "image/png"},
+	{[]byte("GIF87a"), "GIF", "image/gif"},
+	{[]byte("GIF89a"), "GIF", "image/gif"},
+	{[]byte("II*\x00"), "TIFF", "image/tiff"},
+	{[]byte("MM\x00*"), "TIFF", "image/tiff"},
+	{[]byte("II+\x00"), "TIFF", "image/tiff"},
+	{[]byte("MM\x00+"), "TIFF", "image/tiff"},
+	{[]byte("8BPS"), "PSD", "image/vnd.adobe.photoshop"},
+	{[]byte("%PDF-"), "PDF", "application/pdf"},
+	{[]byte("\x00\x00\x01\x00"), "ICO", "image/x-icon"},
+	{[]byte("\x00\x00\x02\x00"), "CUR", "image/x-icon"},
+	{[]byte("\x00\x00\x00\x0CjP  \r\n\x87\n"), "JP2", "image/jp2"},
+	{[]byte("\xFF\x4F\xFF\x51"), "J2K", "image/jp2"},
+	{[]byte("\x00\x00\x00\x0CJXL \r\n\x87\n"), "JXL", "image/jxl"},
+	{[]byte("\xFF\x0A"), "JXL", "image/jxl"},
+	{[]byte("\xC5\xD0\xD3\xC6"), "EPT", "application/postscript"},
+	{[]byte("gimp xcf"), "XCF", "image/x-xcf"},
+	{[]byte("v/1\x01"), "EXR", "image/x-exr"},
+	{[]byte("DDS "), "DDS", "image/vnd.ms-dds"},
+	{[]byte("qoif"), "QOI", "image/qoi"},
+	{[]byte("icns"), "ICNS", "image/icns"},
+	{[]byte("#?RADIANCE"), "HDR", "image/vnd.radiance"},
+	{[]byte("#?RGBE"), "HDR", "image/vnd.radiance"},
+}
+
+// The PNM formats by the digit in their magic number, like "P6"
+var pnmFormats = map[byte]magicFormat{
+	'1': {nil, "PBM", "image/x-portable-bitmap"},
+	'4': {nil, "PBM", "image/x-portable-bitmap"},
+	'2': {nil, "PGM", "image/x-portable-graymap"},
+	'5': {nil, "PGM", "image/x-portable-graymap"},
+	'3': {nil, "PPM", "image/x-portable-pixmap"},
+	'6': {nil, "PPM", "image/x-portable-pixmap"},
+	'7': {nil, "PAM", "image/x-portable-arbitrarymap"},
+}
+
+// The ISO base media file format brands of HEIC and AVIF images
+var (
+	heicBrands = []string{"heic", "heix", "heim", "heis", "hevc", "hevx", "hevm", "hevs"}
+	avifBrands = []string{"avif", "avis"}
+)
+
+// SniffFormat identifies the image format from the first bytes read from r, returning the
+// ImageMagick coder name (like "JPEG") and the MIME type (like "image/jpeg").  It recognizes JPEG,
+// PNG, GIF, WebP, TIFF, BMP, HEIC, AVIF, PSD, SVG, PDF, PostScript, ICO, JPEG 2000, JPEG XL, PNM
+// and a few more.  If the format is not recognized, the error is ErrUnknownFormat.  Note that
+// formats without a magic number, like TGA and raw pixel formats, are never recognized.
+func SniffFormat(r io.Reader) (coder string, mimeType string, err error) {
+	header := make([]byte, sniffLength)
+	n, err := io.ReadFull(r, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", "", err
+	}
+	header = header[:n]
+
+	coder, mimeType = sniffHeader(header)
+	if coder == "" {
+		return "", "", ErrUnknownFormat
+	}
+	return coder, mimeType, nil
+}
+
+// sniffHeader identifies the image format from the first bytes of the data, returning an empty
+// coder if it is not recognized
+func sniffHeader(header []byte) (coder string, mimeType string) {
+	// Formats with a magic number after the start
+	if len(header) >= 12 && bytes.HasPrefix(header, []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WEBP")) {
+		return "WEBP", "image/webp"
+	}
+
+	if len(header) >= 12 && bytes.Equal(header[4:8], []byte("ftyp")) {
+		if coder, mimeType = sniffFileType(header); coder != "" {
+			return
+		}
+	}
+
+	if bytes.HasPrefix(header, []byte("%!PS")) {
+		// Encapsulated PostScript is marked on the first line, like "%!PS-Adobe-3.0 EPSF-3.0"
+		firstLine := header
+		if end := bytes.IndexAny(header, "\r\n"); end >= 0 {
+			firstLine = header[:end]
+		}
+		if bytes.Contains(firstLine, []byte("EPSF")) {
+			return "EPS", "application/postscript"
+		}
+		return "PS", "application/postscript"
+	}
+
+	for _, format := range magicFormats {
+		if bytes.HasPrefix(header, format.magic) {
+			return format.coder, format.mimeType
+		}
+	}
+
+	// Short magic numbers need more checks to avoid matching text files
+	if len(header) >= 3 && header[0] == 'P' && isSpace(header[2]) {
+		if format, ok := pnmFormats[header[1]]; ok {
+			return format.coder, format.mimeType
+		}
+	}
+
+	// "BM" is followed by the file size and 4 reserved bytes, which are 0
+	if len(header) >= 14 && bytes.HasPrefix(header, []byte("BM")) && bytes.Equal(header[6:10], []byte{0, 0, 0, 0}) {
+		return "BMP", "image/bmp"
+	}
+
+	if isSVG(header) {
+		return "SVG", "image/svg+xml"
+	}
+
+	return "", ""
+}
+
+// sniffFileType identifies HEIC and AVIF images by the brands in the ISO base media file format
+// "ftyp" box
+func sniffFileType(header []byte) (coder string, mimeType string) {
+	size := int(header[0])<<24 | int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+	if size < 16 || size > len(header) {
+		size = len(header)
+	}
+
+	// The major brand, then the minor version, then the compatible brands
+	brands := []string{string(header[8:12])}
+	for i := 16; i+4 <= size; i += 4 {
+		brands = append(brands, string(header[i:i+4]))
+	}
+
+	for _, brand := range brands {
+		for _, avif := range avifBrands {
+			if brand == avif {
+				return "AVIF", "image/avif"
+			}
+		}
+		for _, heic := range heicBrands {
+			if brand == heic {
+				return "HEIC", "image/heic"
+			}
+		}
+	}
+
+	return "", ""
+}
+
+// isSpace returns true for the whitespace that ends a PNM magic number
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// isSVG returns true if the data is XML with an <svg> root element
+func isSVG(header []byte) bool {
+	text := bytes.TrimPrefix(header, []byte("\xEF\xBB\xBF"))
+	text = bytes.TrimLeft(text, " \t\r\n")
+	if !bytes.HasPrefix(text, []byte("<")) {
+		return false
+	}
+	return bytes.Contains(text, []byte("<svg")) || bytes.Contains(text, []byte("<SVG"))
+}
+
+// sniffFile returns the ImageMagick coder of a file, from its first bytes.  Relative names are
+// opened in Parser.Dir, so the same file is sniffed that `convert` reads.
+func (parser *Parser) sniffFile(file string) (coder string, err error) {
+	f, err := os.Open(parser.localPath(file))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnableToOpen, err)
+	}
+	defer f.Close()
+
+	coder, _, err = SniffFormat(f)
+	if err == ErrUnknownFormat {
+		return "", fmt.Errorf("%w: %q", ErrUnknownFormat, file)
+	}
+	return coder, err
+}
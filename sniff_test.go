@@ -0,0 +1,156 @@
+package imagemagick_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kamermans/imagemagick"
+	test "github.com/kamermans/imagemagick/test_resources"
+)
+
+var sniffTests = []struct {
+	header   string
+	coder    string
+	mimeType string
+}{
+	{"\xFF\xD8\xFF\xE0\x00\x10JFIF", "JPEG", "image/jpeg"},
+	{"\x89PNG\r\n\x1A\n\x00\x00\x00\rIHDR", "PNG", "image/png"},
+	{"GIF89a\x01\x00\x01\x00", "GIF", "image/gif"},
+	{"RIFF\x24\x00\x00\x00WEBPVP8 ", "WEBP", "image/webp"},
+	{"II*\x00\x08\x00\x00\x00", "TIFF", "image/tiff"},
+	{"MM\x00*\x00\x00\x00\x08", "TIFF", "image/tiff"},
+	{"BM\x46\x00\x00\x00\x00\x00\x00\x00\x36\x00\x00\x00", "BMP", "image/bmp"},
+	{"\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic", "HEIC", "image/heic"},
+	{"\x00\x00\x00\x1CftypMA1B\x00\x00\x00\x00mif1avifmiaf", "AVIF", "image/avif"},
+	{"8BPS\x00\x01", "PSD", "image/vnd.adobe.photoshop"},
+	{"\xEF\xBB\xBF<?xml version=\"1.0\"?>\n<!DOCTYPE svg>\n<svg xmlns=\"http://www.w3.org/2000/svg\">", "SVG", "image/svg+xml"},
+	{"%PDF-1.7\n", "PDF", "application/pdf"},
+	{"%!PS-Adobe-3.0 EPSF-3.0\n", "EPS", "application/postscript"},
+	{"%!PS-Adobe-3.0\n", "PS", "application/postscript"},
+	{"\x00\x00\x01\x00\x01\x00\x10\x10", "ICO", "image/x-icon"},
+	{"\x00\x00\x00\x0CjP  \r\n\x87\n", "JP2", "image/jp2"},
+	{"\xFF\x0A\xFA", "JXL", "image/jxl"},
+	{"P6\n640 480\n255\n", "PPM", "image/x-portable-pixmap"},
+}
+
+func TestSniffFormat(t *testing.T) {
+	for _, tt := range sniffTests {
+		coder, mimeType, err := imagemagick.SniffFormat(strings.NewReader(tt.header))
+		if err != nil {
+			t.Fatalf("SniffFormat(%q) failed: %v", tt.header, err)
+		}
+
+		if coder != tt.coder || mimeType != tt.mimeType {
+			t.Fatalf("SniffFormat(%q) failed: expected %v %v, got %v %v", tt.header, tt.coder, tt.mimeType, coder, mimeType)
+		}
+	}
+
+	unknown := []string{"", "hello world", "P6x", "BMW is a car brand", "<?xml version=\"1.0\"?><image><read filename=\"x\"/></image>"}
+	for _, header := range unknown {
+		if _, _, err := imagemagick.SniffFormat(bytes.NewReader([]byte(header))); err != imagemagick.ErrUnknownFormat {
+			t.Fatalf("SniffFormat(%q) failed: expected ErrUnknownFormat, got %v", header, err)
+		}
+	}
+}
+
+func TestSniffInputs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imagemagick")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	contents := map[string]string{
+		"good_1.png":  sniffTests[1].header,
+		"good_2.jpg":  sniffTests[1].header,
+		"notes.txt":   "just some text",
+		"script.jpg":  "<?xml version=\"1.0\"?><image><read filename=\"x\"/></image>",
+		"good_3.webp": sniffTests[3].header,
+	}
+
+	files := []string{}
+	for name, content := range contents {
+		file := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatalf("Unable to write %v: %v", file, err)
+		}
+		files = append(files, file)
+	}
+	files = append(files, filepath.Join(dir, "missing.png"))
+
+	mockExec := test.NewMockExec("TestHelperBatch")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+	parser.Workers = 1
+	parser.BatchSize = 10
+	parser.SniffInputs = true
+
+	results, errs := runBatchRetry(t, parser, files)
+	if len(results) != 3 || len(errs) != 3 {
+		t.Fatalf("SniffInputs failed: expected 3 results and 3 errors, got %v and %v", len(results), len(errs))
+	}
+
+	for _, err := range errs {
+		expected := imagemagick.ErrUnknownFormat
+		if strings.HasSuffix(err.File(), "missing.png") {
+			expected = imagemagick.ErrUnableToOpen
+		}
+
+		if !errors.Is(err, expected) {
+			t.Fatalf("SniffInputs failed: expected %v for %v, got %v", expected, err.File(), err)
+		}
+	}
+
+	if mockExec.RunCount() != 1 {
+		t.Fatalf("SniffInputs failed: expected 1 run, got %v", mockExec.RunCount())
+	}
+
+	// The sniffed coder is forced instead of the one from the extension
+	parser.SafeFilenames = true
+	results, errs = runBatchRetry(t, parser, []string{filepath.Join(dir, "good_2.jpg")})
+	if len(errs) != 0 || len(results) != 1 || !strings.HasPrefix(results[0].Image.Name, "PNG:") {
+		t.Fatalf("SniffInputs failed: expected a PNG coder prefix, got %v and %v", results, errs)
+	}
+}
+
+func TestSniffInputsCoderAndFrames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imagemagick")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"photo.jpg", "scan[0]"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(sniffTests[1].header), 0644); err != nil {
+			t.Fatalf("Unable to write %v: %v", name, err)
+		}
+	}
+
+	mockExec := test.NewMockExec("TestHelperBatch")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+	parser.Dir = dir
+	parser.SniffInputs = true
+
+	// The prefix and frame selection are removed unless the literal name exists
+	files := []string{"JPEG:photo.jpg", "photo.jpg[0]", "PNG:photo.jpg[1-2]", "scan[0]"}
+	results, parserErr := parser.GetImageDetails(files...)
+	if parserErr != nil {
+		t.Fatalf("GetImageDetails() failed: %v", parserErr.Error())
+	}
+	if len(results) != len(files) {
+		t.Fatalf("GetImageDetails() failed: expected %v results, got %v", len(files), len(results))
+	}
+
+	_, parserErr = parser.GetImageDetails("JPEG:missing.jpg[0]")
+	if parserErr == nil || !errors.Is(parserErr, imagemagick.ErrUnableToOpen) {
+		t.Fatalf("GetImageDetails() failed: expected ErrUnableToOpen, got %v", parserErr)
+	}
+}
@@ -0,0 +1,256 @@
+package imagemagick
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+// jsonCleanerLookBehind is the number of bytes that are held back at the end of each chunk read
+// by the jsonCleaningReader, since they could be the start of a token that continues in the next
+// chunk.  It's one less than the longest token that jsonCleanerPattern matches (": -1.#IND").
+const jsonCleanerLookBehind = 8
+
+var defaultJSONCleaner = regexp.MustCompile(jsonCleanerPattern)
+
+// jsonCleaningReader is an io.Reader that replaces the C++ NaNs in ImageMagick JSON output on the
+// fly, so large outputs can be decoded without buffering them
+type jsonCleaningReader struct {
+	src     io.Reader
+	cleaner *regexp.Regexp
+	repl    []byte
+
+	buf     []byte
+	pending []byte
+	out     []byte
+	err     error
+}
+
+func newJSONCleaningReader(src io.Reader, cleaner *regexp.Regexp, repl []byte) *jsonCleaningReader {
+	return &jsonCleaningReader{
+		src:     src,
+		cleaner: cleaner,
+		repl:    repl,
+		buf:     make([]byte, 32*1024),
+	}
+}
+
+// Read implements io.Reader
+func (r *jsonCleaningReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		n, err := r.src.Read(r.buf)
+		r.pending = append(r.pending, r.buf[:n]...)
+		if err != nil {
+			r.err = err
+		}
+
+		// Every token starts with ":", so if there is one near the end of the chunk, hold it
+		// back until we know the whole token
+		ready := len(r.pending)
+		if r.err == nil {
+			tailStart := ready - jsonCleanerLookBehind
+			if tailStart < 0 {
+				tailStart = 0
+			}
+			if i := bytes.IndexByte(r.pending[tailStart:], ':'); i >= 0 {
+				ready = tailStart + i
+			}
+		}
+
+		r.out = r.cleaner.ReplaceAll(r.pending[:ready], r.repl)
+		r.pending = append(r.pending[:0], r.pending[ready:]...)
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// ImageResultDecoder reads ImageResults one at a time from ImageMagick JSON output, cleaning it of
+// invalid numbers as it goes.  Unlike GetImageDetailsFromJSON, the whole output is never held in
+// memory, which matters for huge multi-image results like 500-page PDFs.
+type ImageResultDecoder struct {
+	reader  *bufio.Reader
+	decoder *json.Decoder
+	inArray bool
+	done    bool
+}
+
+// NewImageResultDecoder creates an ImageResultDecoder that reads ImageMagick JSON from r.  Both the
+// array output of `convert ... json:-` and a plain sequence of objects are supported.
+func NewImageResultDecoder(r io.Reader) *ImageResultDecoder {
+	return newImageResultDecoder(r, defaultJSONCleaner, []byte(jsonCleanerReplString))
+}
+
+func newImageResultDecoder(r io.Reader, cleaner *regexp.Regexp, repl []byte) *ImageResultDecoder {
+	return &ImageResultDecoder{
+		reader: bufio.NewReader(newJSONCleaningReader(r, cleaner, repl)),
+	}
+}
+
+// Next returns the next ImageResult, or io.EOF when there are no more results
+func (dec *ImageResultDecoder) Next() (result *ImageResult, err error) {
+	if dec.done {
+		return nil, io.EOF
+	}
+
+	if dec.decoder == nil {
+		if err = dec.start(); err != nil {
+			dec.done = true
+			return
+		}
+	}
+
+	if dec.inArray && !dec.decoder.More() {
+		dec.done = true
+		if _, err = dec.decoder.Token(); err != nil {
+			return nil, fmt.Errorf("Unable to decode ImageMagick JSON: %v", err)
+		}
+		return nil, io.EOF
+	}
+
+	result = &ImageResult{}
+	if err = dec.decoder.Decode(result); err != nil {
+		dec.done = true
+		if err == io.EOF && !dec.inArray {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("Unable to decode ImageMagick JSON: %v", err)
+	}
+
+	return
+}
+
+// start peeks at the first non-space byte to see if the results are wrapped in an array
+func (dec *ImageResultDecoder) start() error {
+	for {
+		b, err := dec.reader.Peek(1)
+		if err != nil {
+			if err == io.EOF {
+				return io.EOF
+			}
+			return fmt.Errorf("Unable to decode ImageMagick JSON: %v", err)
+		}
+
+		if !strings.ContainsRune(" \t\r\n", rune(b[0])) {
+			dec.inArray = b[0] == '['
+			break
+		}
+		dec.reader.ReadByte()
+	}
+
+	dec.decoder = json.NewDecoder(dec.reader)
+	if dec.inArray {
+		if _, err := dec.decoder.Token(); err != nil {
+			return fmt.Errorf("Unable to decode ImageMagick JSON: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// StreamImageDetails computes ImageDetails for one or more input files like GetImageDetails, but
+// the results are decoded while `convert` is still writing them and passed to callback one at a
+// time instead of being collected in a slice.  ImageResult.File and ImageResult.Frame are set as
+// with GetImageDetails, except that the results of a file given several times in a row are held
+// back until the file is done.  Since the results are passed on before `convert` exits, its
+// warnings aren't added to them; a warning in Parser.WarningsAsErrors still fails the call once
+// all the results are streamed.  If callback returns an error, `convert` is killed and the error is
+// returned as the cause of the ParserError.
+func (parser *Parser) StreamImageDetails(
+	ctx context.Context,
+	callback func(result *ImageResult) error,
+	files ...string,
+) (err *ParserError) {
+	settings, inputs, err := parser.inputArgs(files, nil)
+	if err != nil {
+		return
+	}
+
+	args := make([]string, 0, len(settings)+len(inputs)+1)
+	args = append(args, settings...)
+	args = append(args, inputs...)
+	args = append(args, "json:-")
+
+	return parser.streamImageDetails(ctx, nil, callback, files, inputs, args...)
+}
+
+// streamImageDetails runs `convert` with the given arguments and stdin, decoding the JSON
+// results from its stdout as they are written and matching them to the files, which were passed
+// to `convert` with the given names
+func (parser *Parser) streamImageDetails(
+	ctx context.Context,
+	stdin io.Reader,
+	callback func(result *ImageResult) error,
+	files []string,
+	names []string,
+	args ...string,
+) (err *ParserError) {
+	file := strings.Join(files, ", ")
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stdoutReader, stdoutWriter := io.Pipe()
+	var stderr bytes.Buffer
+
+	cmdDone := make(chan error, 1)
+	go func() {
+		cmdErr := parser.run(runCtx, stdin, stdoutWriter, &stderr, args...)
+		stdoutWriter.Close()
+		cmdDone <- cmdErr
+	}()
+
+	var streamErr error
+	send := func(results []*ImageResult) {
+		for _, result := range results {
+			if streamErr = callback(result); streamErr != nil {
+				return
+			}
+		}
+	}
+
+	matcher := newResultMatcher(files, names)
+	decoder := newImageResultDecoder(stdoutReader, parser.jsonCleaner, parser.jsonCleanerRepl)
+	for streamErr == nil {
+		result, decodeErr := decoder.Next()
+		if decodeErr == io.EOF {
+			send(matcher.flush())
+			break
+		}
+		if decodeErr != nil {
+			streamErr = decodeErr
+			break
+		}
+		send(matcher.add(result))
+	}
+
+	// Stop convert early if we're giving up on its output, then drain the pipe so it can exit
+	if streamErr != nil {
+		cancel()
+	}
+	io.Copy(ioutil.Discard, stdoutReader)
+	cmdErr := <-cmdDone
+
+	switch {
+	case cmdErr != nil && (streamErr == nil || ctx.Err() != nil):
+		err = parser.newCommandError(cmdErr, file, args, []byte{}, stderr.Bytes())
+	case streamErr != nil:
+		err = NewParserError(streamErr.Error(), file, parser.commandString(args), []byte{}, stderr.Bytes())
+		err.cause = streamErr
+	default:
+		err = parser.checkWarnings(files, args, nil, stderr.Bytes())
+	}
+
+	return
+}
@@ -0,0 +1,245 @@
+package imagemagick_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/kamermans/imagemagick"
+	test "github.com/kamermans/imagemagick/test_resources"
+)
+
+func decodeAll(r io.Reader) (results []*imagemagick.ImageResult, err error) {
+	decoder := imagemagick.NewImageResultDecoder(r)
+	for {
+		result, nextErr := decoder.Next()
+		if nextErr == io.EOF {
+			return
+		}
+		if nextErr != nil {
+			return results, nextErr
+		}
+		results = append(results, result)
+	}
+}
+
+func TestImageResultDecoder(t *testing.T) {
+	files, err := filepath.Glob("test_resources/json_output/*.json")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("Cannot read JSON test files")
+	}
+
+	parser := imagemagick.NewParser()
+
+	for _, file := range files {
+		shouldFail := strings.Contains(file, "_error_")
+		jsonBlob, readErr := ioutil.ReadFile(file)
+		if readErr != nil {
+			t.Fatalf("Cannot read JSON test file %q: %v", file, readErr.Error())
+		}
+
+		// Reading one byte at a time makes sure tokens split between reads are cleaned
+		results, decodeErr := decodeAll(iotest.OneByteReader(bytes.NewReader(jsonBlob)))
+
+		if shouldFail {
+			if decodeErr == nil {
+				t.Fatalf("ImageResultDecoder was expected to fail on %q but it did not", file)
+			}
+			continue
+		}
+
+		if decodeErr != nil {
+			t.Fatalf("ImageResultDecoder failed on %q: %v", file, decodeErr.Error())
+		}
+
+		expected, jsonErr := parser.GetImageDetailsFromJSON(&jsonBlob)
+		if jsonErr != nil {
+			t.Fatalf("Cannot decode JSON from test file %q: %v", file, jsonErr.Error())
+		}
+
+		if len(results) != len(expected) {
+			t.Fatalf("ImageResultDecoder failed on %q: expected %v results, got %v", file, len(expected), len(results))
+		}
+
+		for i := range results {
+			if results[i].Image.Name != expected[i].Image.Name || results[i].Image.Filesize != expected[i].Image.Filesize {
+				t.Fatalf("ImageResultDecoder failed on %q: result %v does not match", file, i)
+			}
+		}
+	}
+}
+
+func TestImageResultDecoderObjectSequence(t *testing.T) {
+	jsonBlob := `{"image": {"name": "a.jpg", "gamma": -1.#IND}}
+{"image": {"name": "b.jpg", "gamma": -nan}}`
+
+	results, err := decodeAll(strings.NewReader(jsonBlob))
+	if err != nil {
+		t.Fatalf("ImageResultDecoder failed: %v", err.Error())
+	}
+
+	if len(results) != 2 || results[0].Image.Name != "a.jpg" || results[1].Image.Name != "b.jpg" {
+		t.Fatalf("ImageResultDecoder failed: unexpected results %v", results)
+	}
+
+	results, err = decodeAll(strings.NewReader("  \n"))
+	if err != nil || len(results) != 0 {
+		t.Fatalf("ImageResultDecoder failed on empty input: %v %v", results, err)
+	}
+}
+
+func TestStreamImageDetails(t *testing.T) {
+	file := "test_resources/json_output/image_metadata_multi_formats_linux2.json"
+	mockExec := test.NewMockExec("TestHelperGetImageDetails")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+
+	count := 0
+	err := parser.StreamImageDetails(context.Background(), func(result *imagemagick.ImageResult) error {
+		if result.Image == nil {
+			t.Fatalf("StreamImageDetails() failed: result has no image")
+		}
+		if result.File != file || result.Frame != count {
+			t.Fatalf("StreamImageDetails() failed: expected frame %v of %v, got frame %v of %v", count, file, result.Frame, result.File)
+		}
+		count++
+		return nil
+	}, file)
+
+	if err != nil {
+		t.Fatalf("StreamImageDetails() failed: %v", err.Error())
+	}
+
+	if count != 40 {
+		t.Fatalf("StreamImageDetails() failed: expected 40 results, got %v", count)
+	}
+
+	args := mockExec.LastRun().Args()
+	if len(args) != 2 || args[0] != file || args[1] != "json:-" {
+		t.Fatalf("StreamImageDetails() failed: unexpected args %v", args)
+	}
+}
+
+func TestStreamImageDetailsFiles(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperGetImageDetailsWarnings")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+
+	results := []*imagemagick.ImageResult{}
+	callback := func(result *imagemagick.ImageResult) error {
+		results = append(results, result)
+		return nil
+	}
+
+	if err := parser.StreamImageDetails(context.Background(), callback, multiLinuxFiles...); err != nil {
+		t.Fatalf("StreamImageDetails() failed: %v", err.Error())
+	}
+
+	if len(results) != len(multiLinuxFiles) {
+		t.Fatalf("StreamImageDetails() failed: expected %v results, got %v", len(multiLinuxFiles), len(results))
+	}
+	for i, result := range results {
+		if result.File != multiLinuxFiles[i] || result.Frame != 0 {
+			t.Fatalf("StreamImageDetails() failed: expected frame 0 of %v, got frame %v of %v", multiLinuxFiles[i], result.Frame, result.File)
+		}
+	}
+
+	// The warnings are checked once convert exits
+	parser.WarningsAsErrors = []imagemagick.ErrorCategory{imagemagick.CategoryCorruptImage}
+	err := parser.StreamImageDetails(context.Background(), callback, multiLinuxFiles...)
+	if err == nil || !errors.Is(err, imagemagick.ErrCorruptImage) {
+		t.Fatalf("StreamImageDetails() failed: expected a corrupt image error, got %v", err)
+	}
+}
+
+func TestStreamImageDetailsAdjacentCopies(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperBatch")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+
+	results := []*imagemagick.ImageResult{}
+	err := parser.StreamImageDetails(context.Background(), func(result *imagemagick.ImageResult) error {
+		results = append(results, result)
+		return nil
+	}, "/foo/multi.gif", "/foo/multi.gif", "/foo/a.png")
+	if err != nil {
+		t.Fatalf("StreamImageDetails() failed: %v", err.Error())
+	}
+
+	expectedFrames := []int{0, 1, 2, 0, 1, 2, 0}
+	if len(results) != len(expectedFrames) {
+		t.Fatalf("StreamImageDetails() failed: expected %v results, got %v", len(expectedFrames), len(results))
+	}
+	for i, result := range results {
+		if result.File != result.Image.Name || result.Frame != expectedFrames[i] {
+			t.Fatalf("StreamImageDetails() failed: expected frame %v of %v, got frame %v of %v",
+				expectedFrames[i], result.Image.Name, result.Frame, result.File)
+		}
+	}
+
+	// Results named after other files are passed on without a File
+	mockExec = test.NewMockExec("TestHelperGetImageDetailsWarnings")
+	parser.SetCommand(mockExec.Command)
+	err = parser.StreamImageDetails(context.Background(), func(result *imagemagick.ImageResult) error {
+		if result.File != "" {
+			t.Fatalf("StreamImageDetails() failed: result of %v was matched to %v", result.Image.Name, result.File)
+		}
+		return nil
+	}, "/foo/one.png", "/foo/two.png")
+	if err != nil {
+		t.Fatalf("StreamImageDetails() failed: %v", err.Error())
+	}
+}
+
+func TestStreamImageDetailsCallbackError(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperGetImageDetails")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+
+	stopErr := errors.New("seen enough")
+	count := 0
+	err := parser.StreamImageDetails(context.Background(), func(result *imagemagick.ImageResult) error {
+		count++
+		if count == 3 {
+			return stopErr
+		}
+		return nil
+	}, "foo.pdf")
+
+	if err == nil {
+		t.Fatalf("StreamImageDetails() did not fail as expected")
+	}
+
+	if err.Unwrap() != stopErr || count != 3 {
+		t.Fatalf("StreamImageDetails() failed: expected the callback error after 3 results, got %v after %v", err.Unwrap(), count)
+	}
+}
+
+func TestStreamImageDetailsFailed(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperGetImageDetailsFailed")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+
+	err := parser.StreamImageDetails(context.Background(), func(result *imagemagick.ImageResult) error {
+		return nil
+	}, "foo.pdf")
+
+	if err == nil {
+		t.Fatalf("StreamImageDetails() did not fail as expected")
+	}
+
+	if string(err.StdErr()) != "Simulated Failure" {
+		t.Fatalf("StreamImageDetails() failed: expected stderr to be captured, got %q", err.StdErr())
+	}
+}
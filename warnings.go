@@ -0,0 +1,106 @@
+package imagemagick
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Matches a coder prefix like "json:" or "JPEG:" (but not a Windows drive letter like "C:")
+var coderPrefixPattern = regexp.MustCompile(`^[A-Za-z0-9-]{2,}:`)
+
+// Matches a frame selection suffix like "[0]" or "[1-3]"
+var frameSuffixPattern = regexp.MustCompile(`\[[^\[\]]*\]$`)
+
+// normalizeImageName removes the coder prefix and frame selection that ImageMagick may add to
+// (or the caller may have given in) an image name, so it can be compared with an input file
+func normalizeImageName(name string) string {
+	name = coderPrefixPattern.ReplaceAllString(name, "")
+	name = frameSuffixPattern.ReplaceAllString(name, "")
+	return name
+}
+
+// imageNameMatches returns true if the name reported by ImageMagick refers to the given file, or
+// unless exact is set, if they only have the same base name
+func imageNameMatches(name string, file string, exact bool) bool {
+	name = normalizeImageName(name)
+	file = normalizeImageName(file)
+	if name == "" || file == "" {
+		return false
+	}
+	return name == file || (!exact && filepath.Base(name) == filepath.Base(file))
+}
+
+// resultMatchesFile returns true if the result's Name or BaseName refers to the given file, as
+// with imageNameMatches
+func resultMatchesFile(result *ImageResult, file string, exact bool) bool {
+	if result.Image == nil {
+		return false
+	}
+	return imageNameMatches(result.Image.Name, file, exact) || imageNameMatches(result.Image.BaseName, file, exact)
+}
+
+// attachWarnings adds the diagnostics reported by a successful `convert` run to the Warnings of
+// the results for the file they refer to.  Diagnostics that can't be attributed to any of the
+// results are added to all of them.
+func attachWarnings(files []string, results []*ImageResult, diagnostics []*Diagnostic) {
+	for _, diagnostic := range diagnostics {
+		attached := false
+
+		if len(files) > 1 && diagnostic.File != "" {
+			for _, result := range results {
+				if imageNameMatches(diagnostic.File, result.File, false) || resultMatchesFile(result, diagnostic.File, false) {
+					result.Warnings = append(result.Warnings, diagnostic)
+					attached = true
+				}
+			}
+		}
+
+		if !attached {
+			for _, result := range results {
+				result.Warnings = append(result.Warnings, diagnostic)
+			}
+		}
+	}
+}
+
+// escalatedWarnings returns the diagnostics with a category in Parser.WarningsAsErrors
+func (parser *Parser) escalatedWarnings(diagnostics []*Diagnostic) (escalated []*Diagnostic) {
+	for _, diagnostic := range diagnostics {
+		for _, category := range parser.WarningsAsErrors {
+			if diagnostic.Category == category {
+				escalated = append(escalated, diagnostic)
+				break
+			}
+		}
+	}
+	return
+}
+
+// checkWarnings parses the stderr of a successful `convert` run and attaches the warnings to the
+// results, returning an error if any of them are in Parser.WarningsAsErrors
+func (parser *Parser) checkWarnings(files []string, args []string, results []*ImageResult, stdErr []byte) (err *ParserError) {
+	diagnostics := ParseDiagnostics(stdErr)
+	if len(diagnostics) == 0 {
+		return
+	}
+
+	if escalated := parser.escalatedWarnings(diagnostics); len(escalated) > 0 {
+		reasons := make([]string, 0, len(escalated))
+		for _, diagnostic := range escalated {
+			reasons = append(reasons, diagnostic.Category.String())
+		}
+
+		err = NewParserError(
+			"ImageMagick reported warnings that are treated as errors: "+strings.Join(reasons, ", "),
+			strings.Join(files, ", "),
+			parser.commandString(args),
+			[]byte{},
+			stdErr,
+		)
+		return
+	}
+
+	attachWarnings(files, results, diagnostics)
+	return
+}
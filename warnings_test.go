@@ -0,0 +1,99 @@
+package imagemagick_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/kamermans/imagemagick"
+	test "github.com/kamermans/imagemagick/test_resources"
+)
+
+// The base names of the images in image_metadata_multi_linux.json
+var multiLinuxFiles = []string{
+	"/data/441c442cdaa955abe56bc78a113f1c1a.img",
+	"/data/441f7ffd794dece51aeb18c4a6e006d2.img",
+	"/data/4420836ec710376f7f92edfd4ceecf77.img",
+	"/data/442e2eb49a6b9a2c0d0eff2fbe9602cb.img",
+}
+
+func TestGetImageDetailsWarnings(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperGetImageDetailsWarnings")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+
+	results, err := parser.GetImageDetails(multiLinuxFiles...)
+	if err != nil {
+		t.Fatalf("GetImageDetails() failed: %v", err.Error())
+	}
+
+	if len(results) != 4 {
+		t.Fatalf("GetImageDetails() failed: expected 4 results, got %v", len(results))
+	}
+
+	expected := []int{1, 2, 1, 1}
+	for i, result := range results {
+		if len(result.Warnings) != expected[i] {
+			t.Fatalf("GetImageDetails() failed: expected %v warnings on result %v, got %v", expected[i], i, len(result.Warnings))
+		}
+	}
+
+	warning := results[1].Warnings[0]
+	if warning.Severity != imagemagick.SeverityWarning || warning.Category != imagemagick.CategoryCorruptImage {
+		t.Fatalf("GetImageDetails() failed: unexpected warning %+v", warning)
+	}
+
+	jBytes, _ := results[1].ToJSON(false)
+	if len(jBytes) == 0 || string(jBytes[:10]) != `{"image":{` {
+		t.Fatalf("ToJSON() failed: warnings should not change the JSON output: %v", string(jBytes))
+	}
+}
+
+func TestGetImageDetailsWarningsAsErrors(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperGetImageDetailsWarnings")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+	parser.WarningsAsErrors = []imagemagick.ErrorCategory{imagemagick.CategoryPolicyDenied}
+
+	// A category that wasn't reported is still a warning
+	if _, err := parser.GetImageDetails(multiLinuxFiles...); err != nil {
+		t.Fatalf("GetImageDetails() failed: %v", err.Error())
+	}
+
+	parser.WarningsAsErrors = append(parser.WarningsAsErrors, imagemagick.CategoryCorruptImage)
+
+	results, err := parser.GetImageDetails(multiLinuxFiles...)
+	if err == nil {
+		t.Fatalf("GetImageDetails() did not fail as expected")
+	}
+
+	if results != nil {
+		t.Fatalf("GetImageDetails() failed: results should be nil on error")
+	}
+
+	if !errors.Is(err, imagemagick.ErrCorruptImage) {
+		t.Fatalf("GetImageDetails() failed: expected a corrupt image error, got %v", err.Error())
+	}
+}
+
+func TestHelperGetImageDetailsWarnings(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	file := "test_resources/json_output/image_metadata_multi_linux.json"
+	jsonBlob, readErr := ioutil.ReadFile(file)
+	if readErr != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", readErr.Error())
+		os.Exit(2)
+	}
+
+	os.Stdout.Write(jsonBlob)
+	fmt.Fprintf(os.Stderr, "convert: Corrupt JPEG data: premature end of data segment `%v' @ warning/jpeg.c/JPEGWarningHandler/389.\n", multiLinuxFiles[1])
+	fmt.Fprintf(os.Stderr, "convert: Unknown field with tag 33000 (0x80e8) encountered. `TIFFReadDirectory' @ warning/tiff.c/TIFFWarnings/1010.\n")
+}
@@ -0,0 +1,371 @@
+package imagemagick
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// XMP namespaces
+const (
+	NamespaceRDF       = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	NamespaceDC        = "http://purl.org/dc/elements/1.1/"
+	NamespaceXMP       = "http://ns.adobe.com/xap/1.0/"
+	NamespaceXMPRights = "http://ns.adobe.com/xap/1.0/rights/"
+	NamespaceXMPMM     = "http://ns.adobe.com/xap/1.0/mm/"
+	NamespaceStEvt     = "http://ns.adobe.com/xap/1.0/sType/ResourceEvent#"
+	NamespacePhotoshop = "http://ns.adobe.com/photoshop/1.0/"
+	NamespaceLightroom = "http://ns.adobe.com/lightroom/1.0/"
+)
+
+// The namespace of the xml:lang attribute
+const namespaceXML = "http://www.w3.org/XML/1998/namespace"
+
+// XMPNode is an element of an XMP packet.  Names are namespace-aware, so Name.Space is the
+// namespace URI, like NamespaceDC, rather than the prefix the packet happens to use.
+type XMPNode struct {
+	Name     xml.Name
+	Attrs    []xml.Attr
+	Text     string
+	Children []*XMPNode
+}
+
+// Attr returns the value of an attribute
+func (node *XMPNode) Attr(space string, local string) (value string, ok bool) {
+	for _, attr := range node.Attrs {
+		if attr.Name.Space == space && attr.Name.Local == local {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+// Child returns the first child element with the given name, or nil if there is none
+func (node *XMPNode) Child(space string, local string) *XMPNode {
+	for _, child := range node.Children {
+		if child.Name.Space == space && child.Name.Local == local {
+			return child
+		}
+	}
+	return nil
+}
+
+// Find returns the first element with the given name, including the node itself, in depth-first
+// order, or nil if there is none
+func (node *XMPNode) Find(space string, local string) *XMPNode {
+	if node.Name.Space == space && node.Name.Local == local {
+		return node
+	}
+	for _, child := range node.Children {
+		if found := child.Find(space, local); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// XMPHistoryEvent is an entry of the xmpMM:History, like a save in Photoshop
+type XMPHistoryEvent struct {
+	Action        string // like "saved" or "converted"
+	When          string // like "2017-07-05T15:02:35+02:00"
+	SoftwareAgent string // like "Adobe Photoshop Lightroom Classic 7.0"
+	InstanceID    string
+	Changed       string // like "/metadata"
+	Parameters    string
+}
+
+// XMP is the metadata of an image from its XMP packet.  The common Dublin Core, XMP, Photoshop,
+// xmpRights and Lightroom properties are decoded to fields, and RDF has the full tree for the
+// others (see Property).
+type XMP struct {
+	// Dublin Core
+	Title       string   // dc:title
+	Description string   // dc:description
+	Creator     []string // dc:creator
+	Rights      string   // dc:rights
+	Keywords    []string // dc:subject
+
+	// XMP basic
+	Rating      *int   // xmp:Rating, from -1 (rejected) to 5, or nil if it isn't set
+	Label       string // xmp:Label, like "Red"
+	CreatorTool string // xmp:CreatorTool
+	CreateDate  string // xmp:CreateDate
+	ModifyDate  string // xmp:ModifyDate
+
+	// Photoshop
+	Headline    string // photoshop:Headline
+	Credit      string // photoshop:Credit
+	Source      string // photoshop:Source
+	City        string // photoshop:City
+	State       string // photoshop:State
+	Country     string // photoshop:Country
+	DateCreated string // photoshop:DateCreated
+
+	// XMP rights management
+	Marked       *bool  // xmpRights:Marked, true if the image is copyrighted, or nil if it isn't set
+	UsageTerms   string // xmpRights:UsageTerms
+	WebStatement string // xmpRights:WebStatement
+
+	// Lightroom
+	HierarchicalKeywords []string // lr:hierarchicalSubject, like "Places|Spain|Málaga"
+
+	// XMP media management
+	History []XMPHistoryEvent // xmpMM:History
+
+	// RDF is the rdf:RDF element
+	RDF *XMPNode
+}
+
+// ParseXMP parses an XMP packet, as written by `convert file xmp:-`
+func ParseXMP(data []byte) (xmp *XMP, err error) {
+	root, err := parseXMPTree(data)
+	if err != nil {
+		return nil, err
+	}
+
+	rdf := root.Find(NamespaceRDF, "RDF")
+	if rdf == nil {
+		return nil, fmt.Errorf("Invalid XMP packet: no rdf:RDF element")
+	}
+
+	xmp = &XMP{RDF: rdf}
+
+	xmp.Title = xmp.Value(NamespaceDC, "title")
+	xmp.Description = xmp.Value(NamespaceDC, "description")
+	xmp.Creator, _ = xmp.Property(NamespaceDC, "creator")
+	xmp.Rights = xmp.Value(NamespaceDC, "rights")
+	xmp.Keywords, _ = xmp.Property(NamespaceDC, "subject")
+
+	if rating, err := strconv.ParseFloat(xmp.Value(NamespaceXMP, "Rating"), 64); err == nil {
+		r := int(rating)
+		xmp.Rating = &r
+	}
+	xmp.Label = xmp.Value(NamespaceXMP, "Label")
+	xmp.CreatorTool = xmp.Value(NamespaceXMP, "CreatorTool")
+	xmp.CreateDate = xmp.Value(NamespaceXMP, "CreateDate")
+	xmp.ModifyDate = xmp.Value(NamespaceXMP, "ModifyDate")
+
+	xmp.Headline = xmp.Value(NamespacePhotoshop, "Headline")
+	xmp.Credit = xmp.Value(NamespacePhotoshop, "Credit")
+	xmp.Source = xmp.Value(NamespacePhotoshop, "Source")
+	xmp.City = xmp.Value(NamespacePhotoshop, "City")
+	xmp.State = xmp.Value(NamespacePhotoshop, "State")
+	xmp.Country = xmp.Value(NamespacePhotoshop, "Country")
+	xmp.DateCreated = xmp.Value(NamespacePhotoshop, "DateCreated")
+
+	if marked, err := strconv.ParseBool(xmp.Value(NamespaceXMPRights, "Marked")); err == nil {
+		xmp.Marked = &marked
+	}
+	xmp.UsageTerms = xmp.Value(NamespaceXMPRights, "UsageTerms")
+	xmp.WebStatement = xmp.Value(NamespaceXMPRights, "WebStatement")
+
+	xmp.HierarchicalKeywords, _ = xmp.Property(NamespaceLightroom, "hierarchicalSubject")
+
+	for _, fields := range xmp.Structs(NamespaceXMPMM, "History") {
+		xmp.History = append(xmp.History, XMPHistoryEvent{
+			Action:        fields[NamespaceStEvt+"action"],
+			When:          fields[NamespaceStEvt+"when"],
+			SoftwareAgent: fields[NamespaceStEvt+"softwareAgent"],
+			InstanceID:    fields[NamespaceStEvt+"instanceID"],
+			Changed:       fields[NamespaceStEvt+"changed"],
+			Parameters:    fields[NamespaceStEvt+"parameters"],
+		})
+	}
+
+	return xmp, nil
+}
+
+// parseXMPTree parses an XML document into a tree of XMPNodes and returns the root element
+func parseXMPTree(data []byte) (root *XMPNode, err error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+
+	var stack []*XMPNode
+	for {
+		token, tokenErr := decoder.Token()
+		if tokenErr == io.EOF {
+			break
+		}
+		if tokenErr != nil {
+			return nil, fmt.Errorf("Invalid XMP packet: %v", tokenErr)
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			node := &XMPNode{Name: token.Name}
+			for _, attr := range token.Attr {
+				// Skip the namespace declarations
+				if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+					continue
+				}
+				node.Attrs = append(node.Attrs, attr)
+			}
+
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, node)
+			} else if root == nil {
+				root = node
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				node := stack[len(stack)-1]
+				node.Text = strings.TrimSpace(node.Text)
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].Text += string(token)
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("Invalid XMP packet: no elements found")
+	}
+	return root, nil
+}
+
+// property returns the element of a property, or its value if it is written as an attribute of
+// rdf:Description
+func (xmp *XMP) property(space string, local string) (node *XMPNode, attr string, ok bool) {
+	for _, description := range xmp.RDF.Children {
+		if description.Name.Space != NamespaceRDF || description.Name.Local != "Description" {
+			continue
+		}
+		if attr, ok = description.Attr(space, local); ok {
+			return nil, attr, true
+		}
+		if node = description.Child(space, local); node != nil {
+			return node, "", true
+		}
+	}
+	return nil, "", false
+}
+
+// container returns the rdf:Seq, rdf:Bag or rdf:Alt of a property element, or nil if it is a
+// simple value
+func container(node *XMPNode) *XMPNode {
+	for _, child := range node.Children {
+		if child.Name.Space == NamespaceRDF {
+			switch child.Name.Local {
+			case "Seq", "Bag", "Alt":
+				return child
+			}
+		}
+	}
+	return nil
+}
+
+// Property returns the values of a property: the items of an array (rdf:Seq, rdf:Bag or rdf:Alt)
+// or the single value of a simple property.  The "x-default" item of a language alternative comes
+// first.  It returns false if the packet doesn't have the property.
+func (xmp *XMP) Property(space string, local string) (values []string, ok bool) {
+	node, attr, ok := xmp.property(space, local)
+	if !ok {
+		return nil, false
+	}
+	if node == nil {
+		return []string{attr}, true
+	}
+
+	array := container(node)
+	if array == nil {
+		if resource, ok := node.Attr(NamespaceRDF, "resource"); ok {
+			return []string{resource}, true
+		}
+		return []string{node.Text}, true
+	}
+
+	for _, item := range array.Children {
+		if item.Name.Space != NamespaceRDF || item.Name.Local != "li" {
+			continue
+		}
+		if lang, _ := item.Attr(namespaceXML, "lang"); lang == "x-default" {
+			values = append([]string{item.Text}, values...)
+		} else {
+			values = append(values, item.Text)
+		}
+	}
+	return values, true
+}
+
+// Value returns the first value of a property (see Property), or "" if the packet doesn't have it
+func (xmp *XMP) Value(space string, local string) string {
+	if values, _ := xmp.Property(space, local); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Structs returns the fields of each item of an array of structures, like xmpMM:History.  The
+// fields are keyed by the namespace URI and the name, like NamespaceStEvt+"action".
+func (xmp *XMP) Structs(space string, local string) (structs []map[string]string) {
+	node, _, ok := xmp.property(space, local)
+	if !ok || node == nil {
+		return nil
+	}
+
+	array := container(node)
+	if array == nil {
+		return nil
+	}
+
+	for _, item := range array.Children {
+		if item.Name.Space != NamespaceRDF || item.Name.Local != "li" {
+			continue
+		}
+
+		fields := map[string]string{}
+		structFields(item, fields)
+
+		// The fields can also be in a nested rdf:Description
+		if description := item.Child(NamespaceRDF, "Description"); description != nil {
+			structFields(description, fields)
+		}
+		structs = append(structs, fields)
+	}
+	return
+}
+
+// structFields adds the fields of a structure, which can be attributes or child elements
+func structFields(node *XMPNode, fields map[string]string) {
+	for _, attr := range node.Attrs {
+		if attr.Name.Space != NamespaceRDF && attr.Name.Space != namespaceXML {
+			fields[attr.Name.Space+attr.Name.Local] = attr.Value
+		}
+	}
+	for _, child := range node.Children {
+		if child.Name.Space != NamespaceRDF {
+			fields[child.Name.Space+child.Name.Local] = child.Text
+		}
+	}
+}
+
+// GetXMP extracts the XMP packet from the file with `convert file xmp:-` and parses it.  The error
+// matches ErrProfileNotFound with errors.Is() if the file has no XMP packet.  The file name is
+// taken literally, as with GetImageDetailsWithOptions.
+func (parser *Parser) GetXMP(file string) (xmp *XMP, err *ParserError) {
+	return parser.GetXMPContext(context.Background(), file)
+}
+
+// GetXMPContext is like GetXMP, but the `convert` process is killed if ctx is canceled or its
+// deadline expires before it exits
+func (parser *Parser) GetXMPContext(ctx context.Context, file string) (xmp *XMP, err *ParserError) {
+	packet, err := parser.extractProfile(ctx, file, "xmp")
+	if err != nil {
+		return
+	}
+
+	xmp, parseErr := ParseXMP(packet)
+	if parseErr != nil {
+		err = NewParserError(parseErr.Error(), file, "", packet, []byte{})
+		err.cause = parseErr
+		return nil, err
+	}
+	return xmp, nil
+}
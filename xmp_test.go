@@ -0,0 +1,143 @@
+package imagemagick_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/kamermans/imagemagick"
+	test "github.com/kamermans/imagemagick/test_resources"
+)
+
+const testXMPPacket = `<?xpacket begin="` + "\ufeff" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+    xmlns:ps="http://ns.adobe.com/photoshop/1.0/"
+    xmlns:xmpRights="http://ns.adobe.com/xap/1.0/rights/"
+    xmp:Rating="4"
+    xmp:Label="Red"
+    xmp:CreatorTool="Adobe Photoshop Lightroom Classic 7.0"
+    xmp:CreateDate="2017-07-05T15:02:35+02:00"
+    ps:City="Málaga"
+    ps:Credit="Example Press"
+    xmpRights:Marked="True">
+   <xmpRights:UsageTerms>
+    <rdf:Alt>
+     <rdf:li xml:lang="en-US">Editorial use only</rdf:li>
+     <rdf:li xml:lang="x-default">Editorial use only (default)</rdf:li>
+    </rdf:Alt>
+   </xmpRights:UsageTerms>
+  </rdf:Description>
+  <rdf:Description rdf:about=""
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:lr="http://ns.adobe.com/lightroom/1.0/"
+    xmlns:xmpMM="http://ns.adobe.com/xap/1.0/mm/"
+    xmlns:stEvt="http://ns.adobe.com/xap/1.0/sType/ResourceEvent#">
+   <dc:title><rdf:Alt><rdf:li xml:lang="x-default">Harbor at sunset</rdf:li></rdf:Alt></dc:title>
+   <dc:creator><rdf:Seq><rdf:li>Jane Doe</rdf:li><rdf:li>John Doe</rdf:li></rdf:Seq></dc:creator>
+   <dc:subject><rdf:Bag><rdf:li>boats</rdf:li><rdf:li>sunset</rdf:li></rdf:Bag></dc:subject>
+   <lr:hierarchicalSubject><rdf:Bag><rdf:li>Places|Spain|Málaga</rdf:li></rdf:Bag></lr:hierarchicalSubject>
+   <xmpMM:History>
+    <rdf:Seq>
+     <rdf:li stEvt:action="saved" stEvt:when="2017-07-05T16:00:00+02:00" stEvt:changed="/metadata"/>
+     <rdf:li rdf:parseType="Resource">
+      <stEvt:action>converted</stEvt:action>
+      <stEvt:parameters>from image/x-canon-cr2 to image/jpeg</stEvt:parameters>
+     </rdf:li>
+     <rdf:li>
+      <rdf:Description stEvt:action="derived" stEvt:softwareAgent="Adobe Photoshop CC"/>
+     </rdf:li>
+    </rdf:Seq>
+   </xmpMM:History>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+
+func checkTestXMP(t *testing.T, xmp *imagemagick.XMP) {
+	if xmp.Title != "Harbor at sunset" || xmp.Label != "Red" || xmp.City != "Málaga" || xmp.Credit != "Example Press" ||
+		xmp.CreatorTool != "Adobe Photoshop Lightroom Classic 7.0" || xmp.CreateDate != "2017-07-05T15:02:35+02:00" {
+		t.Fatalf("unexpected XMP fields: %+v", xmp)
+	}
+	if xmp.Rating == nil || *xmp.Rating != 4 {
+		t.Fatalf("unexpected XMP rating: %v", xmp.Rating)
+	}
+	if xmp.Marked == nil || !*xmp.Marked {
+		t.Fatalf("unexpected XMP marked: %v", xmp.Marked)
+	}
+	if xmp.UsageTerms != "Editorial use only (default)" {
+		t.Fatalf("unexpected XMP usage terms: %q", xmp.UsageTerms)
+	}
+	if !reflect.DeepEqual(xmp.Creator, []string{"Jane Doe", "John Doe"}) {
+		t.Fatalf("unexpected XMP creator: %v", xmp.Creator)
+	}
+	if !reflect.DeepEqual(xmp.Keywords, []string{"boats", "sunset"}) {
+		t.Fatalf("unexpected XMP keywords: %v", xmp.Keywords)
+	}
+	if !reflect.DeepEqual(xmp.HierarchicalKeywords, []string{"Places|Spain|Málaga"}) {
+		t.Fatalf("unexpected XMP hierarchical keywords: %v", xmp.HierarchicalKeywords)
+	}
+
+	expected := []imagemagick.XMPHistoryEvent{
+		{Action: "saved", When: "2017-07-05T16:00:00+02:00", Changed: "/metadata"},
+		{Action: "converted", Parameters: "from image/x-canon-cr2 to image/jpeg"},
+		{Action: "derived", SoftwareAgent: "Adobe Photoshop CC"},
+	}
+	if !reflect.DeepEqual(xmp.History, expected) {
+		t.Fatalf("unexpected XMP history: %+v", xmp.History)
+	}
+}
+
+func TestParseXMP(t *testing.T) {
+	xmp, err := imagemagick.ParseXMP([]byte(testXMPPacket))
+	if err != nil {
+		t.Fatalf("ParseXMP() failed: %v", err)
+	}
+	checkTestXMP(t, xmp)
+
+	if values, ok := xmp.Property(imagemagick.NamespaceDC, "rights"); ok || values != nil {
+		t.Fatalf("Property() failed: expected no dc:rights, got %v", values)
+	}
+	if xmp.Description != "" || xmp.Rights != "" {
+		t.Fatalf("ParseXMP() failed: unexpected fields %+v", xmp)
+	}
+
+	// The prefixes don't matter, only the namespaces
+	if node := xmp.RDF.Find(imagemagick.NamespacePhotoshop, "City"); node != nil {
+		t.Fatalf("Find() failed: ps:City is an attribute, not an element")
+	}
+	if city := xmp.Value(imagemagick.NamespacePhotoshop, "City"); city != "Málaga" {
+		t.Fatalf("Value() failed: expected Málaga, got %q", city)
+	}
+
+	for name, packet := range map[string]string{
+		"empty":     "",
+		"not xml":   "<x:xmpmeta><rdf:RDF>",
+		"no RDF":    `<x:xmpmeta xmlns:x="adobe:ns:meta/"></x:xmpmeta>`,
+		"truncated": testXMPPacket[:200],
+	} {
+		if _, err := imagemagick.ParseXMP([]byte(packet)); err == nil {
+			t.Fatalf("ParseXMP() should fail for %v", name)
+		}
+	}
+}
+
+func TestGetXMP(t *testing.T) {
+	mockExec := test.NewMockExec("TestHelperExtractProfile")
+
+	parser := imagemagick.NewParser()
+	parser.SetCommand(mockExec.Command)
+
+	xmp, err := parser.GetXMP("/foo/photo.jpg")
+	if err != nil {
+		t.Fatalf("GetXMP() failed: %v", err.Error())
+	}
+	checkTestXMP(t, xmp)
+
+	_, err = parser.GetXMP("/foo/noprofile.jpg")
+	if err == nil || !errors.Is(err, imagemagick.ErrProfileNotFound) {
+		t.Fatalf("GetXMP() failed: expected ErrProfileNotFound, got %v", err)
+	}
+}